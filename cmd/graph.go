@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/slimdevl/repro/pkg/permify"
+)
+
+// runGraph renders the relationships of the test tenant, or the expansion of
+// a permission, as a DOT or Mermaid graph:
+//
+//	./tester graph --seed 7 --entity team:team.7 --permission edit --subject user:user.7
+func runGraph(args []string) {
+	var format string
+	var output string
+	var types string
+	var entity string
+	var permission string
+	var subject string
+	var seed int
+	var apiKey string
+
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	flags.StringVar(&format, "format", "dot", "Graph format, dot or mermaid")
+	flags.StringVar(&output, "out", "", "File to write the graph to, stdout when empty")
+	flags.StringVar(&types, "types", strings.Join([]string{Organization, Team, Project}, ","), "Entity types whose relationships are drawn")
+	flags.StringVar(&entity, "entity", "", "Draw the expansion of --permission on this type:id instead of all relationships")
+	flags.StringVar(&permission, "permission", "", "Permission to expand, or whose grant to highlight")
+	flags.StringVar(&subject, "subject", "", "Highlight the path that grants this type:id the permission on --entity")
+	flags.IntVar(&seed, "seed", -1, "Write the relationships of this set before drawing, -1 writes none")
+	flags.StringVar(&apiKey, "api-key", "", "Bearer token for a secured Permify")
+	_ = flags.Parse(args)
+
+	if format != "dot" && format != "mermaid" {
+		log.Fatalf("Unknown graph format %q\n", format)
+	}
+
+	ctx := context.Background()
+	cfg := permify.NewDefaultConfig()
+	cfg.Tenant = TenantId
+	cfg.APIKey = apiKey
+	cfg.Schema = testSchema
+	client := permify.NewClient(cfg)
+
+	if seed >= 0 {
+		addRelationships(client, ctx, makeRelationships(seed))
+	}
+
+	var graph *permify.Graph
+	if entity != "" && permission != "" {
+		tree, err := client.Expand(ctx, &permify.FindRelationshipsRequest{
+			Entity:     parseEntity(entity),
+			Permission: permission,
+		})
+		if err != nil {
+			log.Fatalf("Error expanding %s#%s: %v\n", entity, permission, err)
+		}
+		graph = permify.NewExpandGraph(tree)
+	} else {
+		var relationships []*permify.Relationship
+		for _, t := range strings.Split(types, ",") {
+			err := client.(permify.DataClient).WalkRelationships(ctx, permify.RelationshipFilter{
+				Entity: permify.EntityIDSet{Type: strings.TrimSpace(t)},
+			}, 0, func(r *permify.Relationship) error {
+				relationships = append(relationships, r)
+				return nil
+			})
+			if err != nil {
+				log.Fatalf("Error reading %s relationships: %v\n", t, err)
+			}
+		}
+		graph = permify.NewRelationshipGraph(relationships)
+	}
+
+	if subject != "" && entity != "" && permission != "" {
+		who := parseEntity(subject)
+		explanation, err := client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: who.Type, Id: who.Id}, parseEntity(entity), permission)
+		if err != nil {
+			log.Fatalf("Error explaining %s#%s@%s: %v\n", entity, permission, subject, err)
+		}
+		fmt.Fprint(os.Stderr, explanation)
+		graph.HighlightGrant(explanation)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v\n", output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	render := graph.WriteDOT
+	if format == "mermaid" {
+		render = graph.WriteMermaid
+	}
+	if err := render(w); err != nil {
+		log.Fatalf("Error writing graph: %v\n", err)
+	}
+}
+
+// parseEntity parses a type:id pair.
+func parseEntity(value string) *permify.Entity {
+	entityType, id, ok := strings.Cut(value, ":")
+	if !ok || entityType == "" || id == "" {
+		log.Fatalf("Expected type:id, got %q\n", value)
+	}
+	return &permify.Entity{Type: entityType, Id: id}
+}
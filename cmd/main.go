@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/slimdevl/repro/pkg/permify"
 )
 
@@ -17,17 +21,47 @@ const (
 	DefaultIterations = 100
 	DefaultCount      = 100
 	MaxSleep          = 100
+	CircuitOpenPause  = 250 * time.Millisecond
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "graph" {
+		runGraph(os.Args[2:])
+		return
+	}
+
 	var maxIterations int
 	var relationCount int
 	var rateLimit int
+	var maxAttempts int
+	var retryWrites bool
+	var apiKey string
+	var tokenFile string
+	var adaptive bool
+	var maxRate int
+	var burst int
+	var writeRate int
+	var breaker bool
+	var logRequests bool
+	var metricsAddr string
+	var traceFile string
 
 	// Define command-line flags
 	flag.IntVar(&maxIterations, "iterations", DefaultIterations, "Number of iterations")
 	flag.IntVar(&relationCount, "count", DefaultCount, "Number of iterations")
 	flag.IntVar(&rateLimit, "rate-limit", permify.DefaultRateLimit, "Rate limit")
+	flag.IntVar(&maxAttempts, "max-attempts", permify.DefaultRetryMaxAttempts, "Attempts per request, 1 disables retries")
+	flag.BoolVar(&retryWrites, "retry-writes", true, "Also retry relationship writes and deletes")
+	flag.StringVar(&apiKey, "api-key", "", "Bearer token for a secured Permify")
+	flag.StringVar(&tokenFile, "token-file", "", "File holding the bearer token, re-read when it changes")
+	flag.BoolVar(&adaptive, "adaptive", false, "Adapt the rate limit to the server, starting at --rate-limit")
+	flag.IntVar(&maxRate, "max-rate", 10*permify.DefaultRateLimit, "Ceiling for the adaptive rate limit")
+	flag.IntVar(&burst, "burst", permify.DefaultBurst, "Burst of the rate limiter")
+	flag.IntVar(&writeRate, "write-rate", 0, "Separate rate limit for writes and deletes, 0 shares the global one")
+	flag.BoolVar(&breaker, "breaker", false, "Wrap the client in a circuit breaker and report how often it tripped")
+	flag.BoolVar(&logRequests, "log-requests", false, "Log every request with IDs redacted")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address during the run, e.g. :9090")
+	flag.StringVar(&traceFile, "trace-file", "", "Record a span per call and write them to this JSON file at the end of the run")
 
 	// Parse the command-line flags
 	flag.Parse()
@@ -36,6 +70,63 @@ func main() {
 	cfg := permify.NewDefaultConfig()
 	cfg.Tenant = TenantId
 	cfg.RateLimit = rateLimit
+	cfg.Burst = burst
+	if writeRate > 0 {
+		cfg.BucketLimits = map[permify.Bucket]permify.BucketLimit{
+			permify.BucketWrite:  {Rate: float64(writeRate), Burst: burst},
+			permify.BucketDelete: {Rate: float64(writeRate), Burst: burst},
+		}
+		cfg.GlobalCeiling = true
+	}
+	cfg.APIKey = apiKey
+	if tokenFile != "" {
+		cfg.Authenticator = permify.NewFileTokenAuthenticator(tokenFile)
+	}
+	if metricsAddr != "" {
+		cfg.Metrics = permify.NewMetrics()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", cfg.Metrics)
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+				log.Fatalf("Error serving metrics: %v\n", err)
+			}
+		}()
+		fmt.Printf("serving metrics on http://%s/metrics\n", metricsAddr)
+	}
+	var tracer *permify.RecordingTracer
+	if traceFile != "" {
+		tracer = permify.NewRecordingTracer()
+		cfg.Tracer = tracer
+	}
+	if logRequests {
+		logger := logrus.New()
+		logger.SetLevel(logrus.DebugLevel)
+		cfg.Interceptors = append(cfg.Interceptors,
+			permify.NewRedactionInterceptor(permify.RedactFields("id", "ids")),
+			permify.NewLoggingInterceptor(logger, true),
+		)
+	}
+	if breaker {
+		cfg.CircuitBreaker = permify.NewDefaultCircuitBreakerConfig()
+		cfg.CircuitBreaker.OnStateChange = func(change permify.CircuitStateChange) {
+			fmt.Printf("\ncircuit %s: %s -> %s\n", change.Name, change.From, change.To)
+		}
+	}
+	if adaptive {
+		cfg.AdaptiveRateLimit = permify.NewDefaultAdaptiveRateLimit(float64(maxRate))
+	}
+	cfg.Retry = permify.NewDefaultRetryPolicy()
+	cfg.Retry.MaxAttempts = maxAttempts
+	if retryWrites {
+		// writing or deleting the same tuples twice is harmless for this test
+		cfg.Retry.RetryableOperations = []permify.Operation{
+			permify.OperationCheck,
+			permify.OperationLookup,
+			permify.OperationExpand,
+			permify.OperationWrite,
+			permify.OperationDelete,
+		}
+	}
 	client := permify.NewClient(cfg)
 
 	// Create a new tenant
@@ -91,14 +182,38 @@ func main() {
 
 	close(cleanupCh)
 	fmt.Println("testing complete")
+
+	snapshot := client.(permify.RateLimitReporter).RateLimitSnapshot()
+	if snapshot.Adaptive {
+		fmt.Printf("rate limit converged to %.1f req/s (increases: %d, decreases: %d)\n",
+			snapshot.Rate, snapshot.Increases, snapshot.Decreases)
+	}
+	for bucket, stats := range snapshot.Waits {
+		if stats.Waits > 0 {
+			fmt.Printf("bucket %s: %d calls waited %s in total\n", bucket, stats.Waits, stats.Total)
+		}
+	}
+	for name, stats := range client.(permify.CircuitBreakerReporter).CircuitBreakerStats() {
+		fmt.Printf("circuit %s: %s, tripped %d times, rejected %d calls\n",
+			name, stats.State, stats.Trips, stats.Rejected)
+	}
+	if tracer != nil {
+		if err := tracer.DumpJSON(traceFile); err != nil {
+			log.Fatalf("Error writing spans: %v\n", err)
+		}
+		fmt.Printf("wrote %d spans to %s\n", len(tracer.Spans()), traceFile)
+	}
 }
 
 func addRelationships(client permify.RelationshipClient, ctx context.Context, relationships []*permify.Relationship) {
 	if len(relationships) == 0 {
 		log.Fatalf("No relationships to add in addRelationships! eek\n")
 	}
-	if _, err := client.AddRelationship(ctx, &permify.AddRelationshipRequest{
-		Relationships: relationships,
+	if err := whileCircuitOpen(func() error {
+		_, err := client.AddRelationship(ctx, &permify.AddRelationshipRequest{
+			Relationships: relationships,
+		})
+		return err
 	}); err != nil {
 		log.Fatalf("Error adding relationship: %v\n", err)
 	}
@@ -108,18 +223,20 @@ func deleteRelationships(client permify.RelationshipClient, ctx context.Context,
 	// now delete the relationship in reverse order
 	for i := len(relationships) - 1; i >= 0; i-- {
 		relation := relationships[i]
-		err := client.DeleteRelationship(ctx, &permify.DeleteRelationshipRequest{
-			Filter: permify.RelationshipFilter{
-				Entity: permify.EntityIDSet{
-					Type: relation.Entity.Type,
-					Ids:  []string{relation.Entity.Id},
-				},
-				Relation: relation.Relation,
-				Subject: permify.SubjectIDSet{
-					Type: relation.Subject.Type,
-					Ids:  []string{relation.Subject.Id},
+		err := whileCircuitOpen(func() error {
+			return client.DeleteRelationship(ctx, &permify.DeleteRelationshipRequest{
+				Filter: permify.RelationshipFilter{
+					Entity: permify.EntityIDSet{
+						Type: relation.Entity.Type,
+						Ids:  []string{relation.Entity.Id},
+					},
+					Relation: relation.Relation,
+					Subject: permify.SubjectIDSet{
+						Type: relation.Subject.Type,
+						Ids:  []string{relation.Subject.Id},
+					},
 				},
-			},
+			})
 		})
 		if err != nil {
 			log.Fatalf("Error deleting relationship %s:%s -> %s -> %s:%s: %s\n",
@@ -131,6 +248,18 @@ func deleteRelationships(client permify.RelationshipClient, ctx context.Context,
 	}
 }
 
+// whileCircuitOpen repeats call as long as the circuit breaker rejects it,
+// so an open circuit pauses the run instead of ending it.
+func whileCircuitOpen(call func() error) error {
+	for {
+		err := call()
+		if !errors.Is(err, permify.ErrCircuitOpen) {
+			return err
+		}
+		time.Sleep(CircuitOpenPause)
+	}
+}
+
 const (
 	User         = "user"
 	Organization = "organization"
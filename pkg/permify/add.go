@@ -17,7 +17,7 @@ func (c *client) AddRelationship(ctx context.Context, request *AddRelationshipRe
 	}
 
 	url := c.constructURL(RelationshipAPIPath)
-	body, err := c.sendRequest(ctx, http.MethodPost, url, request)
+	body, err := c.sendRequest(ctx, OperationWrite, http.MethodPost, url, request)
 	if err != nil {
 		return nil, fmt.Errorf("permify request failed: %w", err)
 	}
@@ -28,8 +28,9 @@ func (c *client) AddRelationship(ctx context.Context, request *AddRelationshipRe
 	}
 
 	if response.ErrorResponse != nil || response.SnapToken == "" {
-		return nil, ErrUnableToCreateRelationship
+		return nil, responseError(OperationWrite, url, response.ErrorResponse)
 	}
+	c.observeSnapToken(response.SnapToken)
 
 	return &response, nil
 }
@@ -3,8 +3,10 @@ package permify_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/slimdevl/repro/pkg/permify"
@@ -119,3 +121,52 @@ func TestAddRelationship(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestAddRelationshipLeavesRequestUnchanged(t *testing.T) {
+	ctx := context.Background()
+	httpClient, rt := newSequenceClient(mockStep{status: http.StatusOK, body: `{"snap_token":"crackle_pop"}`})
+	config := permify.NewDefaultConfig()
+	config.Client = httpClient
+	config.RateLimit = 1000
+	client := permify.NewClient(config)
+
+	request := &permify.AddRelationshipRequest{
+		Relationships: []*permify.Relationship{
+			{
+				Entity:   &permify.Entity{Type: "doc", Id: "org.doc1"},
+				Relation: "owner",
+				Subject:  &permify.Subject{Type: "user", Id: "user_name.1"},
+			},
+		},
+	}
+	expected, err := json.Marshal(request)
+	assert.NoError(t, err)
+
+	// the same request is sent and marshalled, e.g. for logging, concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := client.AddRelationship(ctx, request)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			body, err := json.Marshal(request)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(body))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, "org.doc1", request.Relationships[0].Entity.Id)
+	assert.Equal(t, "user_name.1", request.Relationships[0].Subject.Id)
+	assert.Equal(t, 8, rt.Calls())
+	for _, req := range rt.requests {
+		var sent permify.AddRelationshipRequest
+		decodeSent(t, req, &sent)
+		assert.Equal(t, "org_doc1", sent.Relationships[0].Entity.Id)
+		assert.Equal(t, "user-_name_1", sent.Relationships[0].Subject.Id)
+	}
+}
@@ -0,0 +1,187 @@
+package permify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AttributeType is the protobuf type of an attribute value, as Permify names it.
+type AttributeType string
+
+const (
+	AttributeTypeBoolean      AttributeType = "type.googleapis.com/base.v1.BooleanValue"
+	AttributeTypeString       AttributeType = "type.googleapis.com/base.v1.StringValue"
+	AttributeTypeInteger      AttributeType = "type.googleapis.com/base.v1.IntegerValue"
+	AttributeTypeDouble       AttributeType = "type.googleapis.com/base.v1.DoubleValue"
+	AttributeTypeBooleanArray AttributeType = "type.googleapis.com/base.v1.BooleanArrayValue"
+	AttributeTypeStringArray  AttributeType = "type.googleapis.com/base.v1.StringArrayValue"
+	AttributeTypeIntegerArray AttributeType = "type.googleapis.com/base.v1.IntegerArrayValue"
+	AttributeTypeDoubleArray  AttributeType = "type.googleapis.com/base.v1.DoubleArrayValue"
+)
+
+var ErrInvalidAttributeValue = errors.New("invalid attribute value")
+
+// Attribute is a typed property of an entity used by Permify's ABAC rules,
+// e.g. document.is_public = true.
+type Attribute struct {
+	Entity    *Entity        `json:"entity" validate:"required"`
+	Attribute string         `json:"attribute" validate:"required"`
+	Value     AttributeValue `json:"value"`
+}
+
+// AttributeValue is a typed attribute value. Data holds a bool, string,
+// int32, float64 or a slice of one of them, matching Type. Use the
+// constructors below rather than filling it in by hand.
+type AttributeValue struct {
+	Type AttributeType `json:"@type"`
+	Data interface{}   `json:"data"`
+}
+
+func BooleanValue(v bool) AttributeValue {
+	return AttributeValue{Type: AttributeTypeBoolean, Data: v}
+}
+
+func StringValue(v string) AttributeValue {
+	return AttributeValue{Type: AttributeTypeString, Data: v}
+}
+
+func IntegerValue(v int32) AttributeValue {
+	return AttributeValue{Type: AttributeTypeInteger, Data: v}
+}
+
+func DoubleValue(v float64) AttributeValue {
+	return AttributeValue{Type: AttributeTypeDouble, Data: v}
+}
+
+func BooleanArrayValue(v ...bool) AttributeValue {
+	return AttributeValue{Type: AttributeTypeBooleanArray, Data: v}
+}
+
+func StringArrayValue(v ...string) AttributeValue {
+	return AttributeValue{Type: AttributeTypeStringArray, Data: v}
+}
+
+func IntegerArrayValue(v ...int32) AttributeValue {
+	return AttributeValue{Type: AttributeTypeIntegerArray, Data: v}
+}
+
+func DoubleArrayValue(v ...float64) AttributeValue {
+	return AttributeValue{Type: AttributeTypeDoubleArray, Data: v}
+}
+
+// UnmarshalJSON decodes the data into the Go type matching the value's type.
+func (v *AttributeValue) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Type AttributeType   `json:"@type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	switch aux.Type {
+	case AttributeTypeBoolean:
+		v.Data, err = decodeAttributeData[bool](aux.Data)
+	case AttributeTypeString:
+		v.Data, err = decodeAttributeData[string](aux.Data)
+	case AttributeTypeInteger:
+		v.Data, err = decodeAttributeData[int32](aux.Data)
+	case AttributeTypeDouble:
+		v.Data, err = decodeAttributeData[float64](aux.Data)
+	case AttributeTypeBooleanArray:
+		v.Data, err = decodeAttributeData[[]bool](aux.Data)
+	case AttributeTypeStringArray:
+		v.Data, err = decodeAttributeData[[]string](aux.Data)
+	case AttributeTypeIntegerArray:
+		v.Data, err = decodeAttributeData[[]int32](aux.Data)
+	case AttributeTypeDoubleArray:
+		v.Data, err = decodeAttributeData[[]float64](aux.Data)
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAttributeValue, aux.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAttributeValue, err)
+	}
+	v.Type = aux.Type
+	return nil
+}
+
+// decodeAttributeData decodes data, a missing value gives the zero value
+// since Permify leaves out zero values.
+func decodeAttributeData[T any](data json.RawMessage) (T, error) {
+	var out T
+	if len(data) == 0 {
+		return out, nil
+	}
+	err := json.Unmarshal(data, &out)
+	return out, err
+}
+
+// validate checks that Data holds the Go type matching Type.
+func (v AttributeValue) validate() error {
+	var ok bool
+	switch v.Type {
+	case AttributeTypeBoolean:
+		_, ok = v.Data.(bool)
+	case AttributeTypeString:
+		_, ok = v.Data.(string)
+	case AttributeTypeInteger:
+		_, ok = v.Data.(int32)
+	case AttributeTypeDouble:
+		_, ok = v.Data.(float64)
+	case AttributeTypeBooleanArray:
+		_, ok = v.Data.([]bool)
+	case AttributeTypeStringArray:
+		_, ok = v.Data.([]string)
+	case AttributeTypeIntegerArray:
+		_, ok = v.Data.([]int32)
+	case AttributeTypeDoubleArray:
+		_, ok = v.Data.([]float64)
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAttributeValue, v.Type)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %T is not a %s", ErrInvalidAttributeValue, v.Data, v.Type)
+	}
+	return nil
+}
+
+// validateAttribute checks if all required fields of an attribute are set correctly.
+func validateAttribute(a *Attribute) error {
+	if a == nil {
+		return errors.New("attribute is nil")
+	}
+	if a.Entity == nil || a.Entity.Type == "" {
+		return errors.New("attribute entity type is missing")
+	}
+	if a.Entity.Id == "" {
+		return errors.New("attribute entity ID is missing")
+	}
+	if a.Attribute == "" {
+		return errors.New("attribute name is missing")
+	}
+	return a.Value.validate()
+}
+
+// validateContext checks that the contextual tuples and attributes are well formed.
+func validateContext(c *Context) error {
+	if c == nil {
+		return nil
+	}
+	for i, r := range c.Tuples {
+		if r == nil {
+			return fmt.Errorf("contextual tuple %d is nil", i)
+		}
+		if err := validate(r); err != nil {
+			return fmt.Errorf("contextual tuple %d validation failed: %w", i, err)
+		}
+	}
+	for i, a := range c.Attributes {
+		if err := validateAttribute(a); err != nil {
+			return fmt.Errorf("contextual attribute %d validation failed: %w", i, err)
+		}
+	}
+	return nil
+}
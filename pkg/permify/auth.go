@@ -0,0 +1,171 @@
+package permify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenRefreshBefore is how long before expiry a callback token is renewed.
+const DefaultTokenRefreshBefore = 30 * time.Second
+
+var ErrMissingCredentials = errors.New("no credentials available")
+
+// Authenticator adds credentials to every request sent by the client.
+type Authenticator interface {
+	// Authenticate sets the credentials on the outgoing request.
+	Authenticate(ctx context.Context, req *http.Request) error
+
+	// Refresh discards any cached credentials and obtains new ones. The client
+	// calls it once when the server rejects a request with a 401.
+	Refresh(ctx context.Context) error
+}
+
+// setBearer sets the bearer token on the request.
+func setBearer(req *http.Request, token string) error {
+	if token == "" {
+		return ErrMissingCredentials
+	}
+	req.Header.Set(AuthorizationHeader, BearerPrefix+token)
+	return nil
+}
+
+// StaticTokenAuthenticator sends the same bearer token with every request.
+// It is used for Config.APIKey when no other Authenticator is configured.
+type StaticTokenAuthenticator struct {
+	Token string
+}
+
+// NewStaticTokenAuthenticator returns an Authenticator for a fixed token.
+func NewStaticTokenAuthenticator(token string) *StaticTokenAuthenticator {
+	return &StaticTokenAuthenticator{Token: token}
+}
+
+func (a *StaticTokenAuthenticator) Authenticate(ctx context.Context, req *http.Request) error {
+	return setBearer(req, a.Token)
+}
+
+// Refresh is a no-op, a static token cannot be renewed.
+func (a *StaticTokenAuthenticator) Refresh(ctx context.Context) error {
+	return nil
+}
+
+// FileTokenAuthenticator reads the bearer token from a file, e.g. a mounted
+// secret. The file is re-read whenever its modification time changes, so a
+// rotated token is picked up without restarting the client.
+type FileTokenAuthenticator struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+// NewFileTokenAuthenticator returns an Authenticator reading its token from path.
+func NewFileTokenAuthenticator(path string) *FileTokenAuthenticator {
+	return &FileTokenAuthenticator{path: path}
+}
+
+func (a *FileTokenAuthenticator) Authenticate(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat token file: %w", err)
+	}
+	if a.token == "" || !info.ModTime().Equal(a.modTime) {
+		if err := a.load(info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return setBearer(req, a.token)
+}
+
+// Refresh re-reads the token file unconditionally.
+func (a *FileTokenAuthenticator) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat token file: %w", err)
+	}
+	return a.load(info.ModTime())
+}
+
+// load reads the token file, the caller must hold the lock.
+func (a *FileTokenAuthenticator) load(modTime time.Time) error {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+	a.token = strings.TrimSpace(string(data))
+	a.modTime = modTime
+	return nil
+}
+
+// TokenSource fetches a new token together with its expiry time. A zero
+// expiry means the token does not expire.
+type TokenSource func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// CallbackAuthenticator obtains short-lived tokens, e.g. from an OIDC
+// provider, and renews them shortly before they expire.
+type CallbackAuthenticator struct {
+	source        TokenSource
+	refreshBefore time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewCallbackAuthenticator returns an Authenticator backed by source. Tokens
+// are renewed refreshBefore ahead of their expiry, a non-positive value uses
+// DefaultTokenRefreshBefore.
+func NewCallbackAuthenticator(source TokenSource, refreshBefore time.Duration) *CallbackAuthenticator {
+	if refreshBefore <= 0 {
+		refreshBefore = DefaultTokenRefreshBefore
+	}
+	return &CallbackAuthenticator{source: source, refreshBefore: refreshBefore}
+}
+
+func (a *CallbackAuthenticator) Authenticate(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" || a.expiring() {
+		if err := a.fetch(ctx); err != nil {
+			return err
+		}
+	}
+	return setBearer(req, a.token)
+}
+
+// Refresh fetches a new token from the source.
+func (a *CallbackAuthenticator) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.fetch(ctx)
+}
+
+// expiring reports whether the cached token is within its refresh window.
+func (a *CallbackAuthenticator) expiring() bool {
+	return !a.expiry.IsZero() && time.Now().Add(a.refreshBefore).After(a.expiry)
+}
+
+// fetch asks the source for a new token, the caller must hold the lock.
+func (a *CallbackAuthenticator) fetch(ctx context.Context) error {
+	token, expiry, err := a.source(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch token: %w", err)
+	}
+	a.token = token
+	a.expiry = expiry
+	return nil
+}
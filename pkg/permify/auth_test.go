@@ -0,0 +1,120 @@
+package permify_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthentication(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+
+	t.Run("APIKey Sent As Bearer Token", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(mockStep{status: http.StatusOK, body: allowed})
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.APIKey = "secret"
+		client := permify.NewClient(config)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer secret", rt.requests[0].Header.Get("Authorization"))
+	})
+
+	t.Run("No Header Without Credentials", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(mockStep{status: http.StatusOK, body: allowed})
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		client := permify.NewClient(config)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Empty(t, rt.requests[0].Header.Get("Authorization"))
+	})
+
+	t.Run("Unauthorized Refreshes Once", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusUnauthorized},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		fetches := 0
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Authenticator = permify.NewCallbackAuthenticator(func(ctx context.Context) (string, time.Time, error) {
+			fetches++
+			return fmt.Sprintf("token-%d", fetches), time.Now().Add(time.Hour), nil
+		}, 0)
+		client := permify.NewClient(config)
+
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 2, fetches)
+		assert.Equal(t, "Bearer token-1", rt.requests[0].Header.Get("Authorization"))
+		assert.Equal(t, "Bearer token-2", rt.requests[1].Header.Get("Authorization"))
+	})
+
+	t.Run("Repeated Unauthorized Fails", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(mockStep{status: http.StatusUnauthorized})
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.APIKey = "wrong"
+		config.Retry = fastRetryPolicy()
+		client := permify.NewClient(config)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		var apiErr *permify.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+		assert.Equal(t, 2, rt.Calls())
+	})
+
+	t.Run("Callback Token Renewed Before Expiry", func(t *testing.T) {
+		fetches := 0
+		auth := permify.NewCallbackAuthenticator(func(ctx context.Context) (string, time.Time, error) {
+			fetches++
+			return "short", time.Now().Add(time.Second), nil
+		}, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			assert.NoError(t, auth.Authenticate(ctx, req))
+		}
+		assert.Equal(t, 3, fetches)
+	})
+
+	t.Run("File Token Rotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		assert.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+		auth := permify.NewFileTokenAuthenticator(path)
+
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+		assert.NoError(t, auth.Authenticate(ctx, req))
+		assert.Equal(t, "Bearer first", req.Header.Get("Authorization"))
+
+		assert.NoError(t, os.WriteFile(path, []byte("second\n"), 0o600))
+		later := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(path, later, later))
+
+		req, _ = http.NewRequest(http.MethodGet, "http://localhost", nil)
+		assert.NoError(t, auth.Authenticate(ctx, req))
+		assert.Equal(t, "Bearer second", req.Header.Get("Authorization"))
+	})
+
+	t.Run("Missing Token File", func(t *testing.T) {
+		auth := permify.NewFileTokenAuthenticator(filepath.Join(t.TempDir(), "missing"))
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+		assert.Error(t, auth.Authenticate(ctx, req))
+	})
+}
@@ -0,0 +1,317 @@
+package permify
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerFailureRate    = 0.5
+	DefaultBreakerMinRequests    = 20
+	DefaultBreakerWindow         = 10 * time.Second
+	DefaultBreakerWindowSlots    = 10
+	DefaultBreakerOpenDuration   = 5 * time.Second
+	DefaultBreakerHalfOpenProbes = 3
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // Calls flow normally
+	CircuitOpen                         // Calls are rejected with ErrCircuitOpen
+	CircuitHalfOpen                     // A limited number of probe calls is let through
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitStateChange describes a transition of one circuit breaker.
+type CircuitStateChange struct {
+	Name string // Host, or host and operation when breaking per operation
+	From CircuitState
+	To   CircuitState
+	At   time.Time
+}
+
+// CircuitBreakerConfig configures the circuit breaker wrapped around every call.
+// Transport errors, timeouts, 429 and 5xx responses count as failures.
+type CircuitBreakerConfig struct {
+	FailureRate    float64       // Fraction of failed calls in the window that opens the circuit
+	MinRequests    int           // Calls needed in the window before the failure rate is evaluated
+	Window         time.Duration // Length of the rolling window
+	WindowSlots    int           // Number of slots the window is divided into
+	OpenDuration   time.Duration // Time the circuit stays open before probing
+	HalfOpenProbes int           // Probe calls in half-open state, all must succeed to close
+	PerOperation   bool          // Keep a separate breaker for every operation on the host
+
+	// OnStateChange is called after every state transition, without holding
+	// any lock of the breaker.
+	OnStateChange func(CircuitStateChange)
+}
+
+// NewDefaultCircuitBreakerConfig returns a circuit breaker configuration
+// suitable for most callers.
+func NewDefaultCircuitBreakerConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		FailureRate:    DefaultBreakerFailureRate,
+		MinRequests:    DefaultBreakerMinRequests,
+		Window:         DefaultBreakerWindow,
+		WindowSlots:    DefaultBreakerWindowSlots,
+		OpenDuration:   DefaultBreakerOpenDuration,
+		HalfOpenProbes: DefaultBreakerHalfOpenProbes,
+	}
+}
+
+// setDefaults fills unset fields with their defaults.
+func (c *CircuitBreakerConfig) setDefaults() {
+	if c.FailureRate <= 0 || c.FailureRate > 1 {
+		c.FailureRate = DefaultBreakerFailureRate
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = DefaultBreakerMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = DefaultBreakerWindow
+	}
+	if c.WindowSlots <= 0 {
+		c.WindowSlots = DefaultBreakerWindowSlots
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = DefaultBreakerOpenDuration
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = DefaultBreakerHalfOpenProbes
+	}
+}
+
+// CircuitBreakerStats describes one circuit breaker.
+type CircuitBreakerStats struct {
+	State    CircuitState
+	Trips    uint64 // Number of times the circuit opened
+	Rejected uint64 // Calls rejected with ErrCircuitOpen
+}
+
+// CircuitBreakerReporter is implemented by clients that can report their circuit breakers.
+type CircuitBreakerReporter interface {
+	// CircuitBreakerStats returns the stats of every breaker keyed by its name.
+	CircuitBreakerStats() map[string]CircuitBreakerStats
+}
+
+// breakerSlot counts outcomes in one slice of the rolling window.
+type breakerSlot struct {
+	successes int
+	failures  int
+}
+
+// circuitBreaker tracks the outcomes of calls in a rolling window.
+type circuitBreaker struct {
+	name   string
+	config *CircuitBreakerConfig
+
+	mu        sync.Mutex
+	state     CircuitState
+	openedAt  time.Time
+	probes    int // probes in flight while half-open
+	successes int // successful probes while half-open
+	slots     []breakerSlot
+	slotStart time.Time // start of the current slot
+	current   int       // index of the current slot
+	trips     uint64
+	rejected  uint64
+}
+
+func newCircuitBreaker(name string, config *CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		name:      name,
+		config:    config,
+		slots:     make([]breakerSlot, config.WindowSlots),
+		slotStart: time.Now(),
+	}
+}
+
+// allow reports whether a call may proceed. A nil breaker allows everything.
+// Every allowed call must be followed by either record or cancel.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	var change *CircuitStateChange
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.config.OpenDuration {
+		change = b.transition(CircuitHalfOpen)
+	}
+
+	var err error
+	switch {
+	case b.state == CircuitOpen:
+		err = fmt.Errorf("%w: %s", ErrCircuitOpen, b.name)
+	case b.state == CircuitHalfOpen && b.probes >= b.config.HalfOpenProbes:
+		err = fmt.Errorf("%w: %s is probing", ErrCircuitOpen, b.name)
+	case b.state == CircuitHalfOpen:
+		b.probes++
+	}
+	if err != nil {
+		b.rejected++
+	}
+	b.mu.Unlock()
+
+	b.notify(change)
+	return err
+}
+
+// cancel gives back an allowed call that never reached the server.
+func (b *circuitBreaker) cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// record feeds the outcome of an allowed call into the breaker.
+func (b *circuitBreaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	var change *CircuitStateChange
+	switch b.state {
+	case CircuitHalfOpen:
+		if b.probes > 0 {
+			b.probes--
+		}
+		if failed {
+			change = b.transition(CircuitOpen)
+		} else if b.successes++; b.successes >= b.config.HalfOpenProbes {
+			change = b.transition(CircuitClosed)
+		}
+	case CircuitClosed:
+		slot := b.advance()
+		if failed {
+			slot.failures++
+		} else {
+			slot.successes++
+		}
+		if b.tripped() {
+			change = b.transition(CircuitOpen)
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(change)
+}
+
+// advance rotates the window up to now and returns the current slot.
+func (b *circuitBreaker) advance() *breakerSlot {
+	slotLength := b.config.Window / time.Duration(len(b.slots))
+	elapsed := time.Since(b.slotStart)
+	if elapsed >= slotLength {
+		steps := int(elapsed / slotLength)
+		if steps > len(b.slots) {
+			steps = len(b.slots)
+		}
+		for i := 0; i < steps; i++ {
+			b.current = (b.current + 1) % len(b.slots)
+			b.slots[b.current] = breakerSlot{}
+		}
+		b.slotStart = b.slotStart.Add((elapsed / slotLength) * slotLength)
+	}
+	return &b.slots[b.current]
+}
+
+// tripped reports whether the failure rate in the window exceeds the threshold.
+func (b *circuitBreaker) tripped() bool {
+	var successes, failures int
+	for _, slot := range b.slots {
+		successes += slot.successes
+		failures += slot.failures
+	}
+	total := successes + failures
+	if total == 0 || total < b.config.MinRequests {
+		return false
+	}
+	return float64(failures)/float64(total) >= b.config.FailureRate
+}
+
+// transition moves the breaker to a new state, the caller must hold the lock.
+func (b *circuitBreaker) transition(to CircuitState) *CircuitStateChange {
+	change := &CircuitStateChange{Name: b.name, From: b.state, To: to, At: time.Now()}
+	b.state = to
+	b.probes = 0
+	b.successes = 0
+	switch to {
+	case CircuitOpen:
+		b.openedAt = change.At
+		b.trips++
+	case CircuitClosed:
+		for i := range b.slots {
+			b.slots[i] = breakerSlot{}
+		}
+		b.slotStart = change.At
+	}
+	return change
+}
+
+func (b *circuitBreaker) notify(change *CircuitStateChange) {
+	if change != nil && b.config.OnStateChange != nil {
+		b.config.OnStateChange(*change)
+	}
+}
+
+func (b *circuitBreaker) stats() CircuitBreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return CircuitBreakerStats{State: b.state, Trips: b.trips, Rejected: b.rejected}
+}
+
+// breakerFor returns the breaker guarding the operation, or nil when the
+// circuit breaker is disabled.
+func (c *client) breakerFor(op Operation) *circuitBreaker {
+	if c.breaker == nil {
+		return nil
+	}
+
+	name := c.config.Host
+	if c.breaker.PerOperation {
+		name = fmt.Sprintf("%s/%s", c.config.Host, op)
+	}
+
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	b, ok := c.breakers[name]
+	if !ok {
+		b = newCircuitBreaker(name, c.breaker)
+		c.breakers[name] = b
+	}
+	return b
+}
+
+// CircuitBreakerStats returns the stats of every breaker keyed by its name.
+func (c *client) CircuitBreakerStats() map[string]CircuitBreakerStats {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	stats := make(map[string]CircuitBreakerStats, len(c.breakers))
+	for name, b := range c.breakers {
+		stats[name] = b.stats()
+	}
+	return stats
+}
@@ -0,0 +1,155 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+
+	newBreakerClient := func(perOperation bool, changes *[]permify.CircuitStateChange, steps ...mockStep) (permify.RelationshipClient, *SequenceRoundTripper) {
+		var mu sync.Mutex
+		httpClient, rt := newSequenceClient(steps...)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1000
+		config.CircuitBreaker = &permify.CircuitBreakerConfig{
+			FailureRate:    0.5,
+			MinRequests:    4,
+			Window:         time.Minute,
+			OpenDuration:   20 * time.Millisecond,
+			HalfOpenProbes: 1,
+			PerOperation:   perOperation,
+			OnStateChange: func(change permify.CircuitStateChange) {
+				mu.Lock()
+				defer mu.Unlock()
+				*changes = append(*changes, change)
+			},
+		}
+		return permify.NewClient(config), rt
+	}
+
+	t.Run("Opens After Failure Rate Exceeded", func(t *testing.T) {
+		var changes []permify.CircuitStateChange
+		client, rt := newBreakerClient(false, &changes, mockStep{status: http.StatusServiceUnavailable})
+
+		for i := 0; i < 4; i++ {
+			_, err := client.CheckPermission(ctx, subject, entity, "view")
+			assert.NotErrorIs(t, err, permify.ErrCircuitOpen)
+		}
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrCircuitOpen)
+		assert.Equal(t, 4, rt.Calls())
+
+		assert.Len(t, changes, 1)
+		assert.Equal(t, permify.CircuitClosed, changes[0].From)
+		assert.Equal(t, permify.CircuitOpen, changes[0].To)
+		assert.Equal(t, "localhost:3476", changes[0].Name)
+
+		stats := client.(permify.CircuitBreakerReporter).CircuitBreakerStats()["localhost:3476"]
+		assert.Equal(t, permify.CircuitOpen, stats.State)
+		assert.Equal(t, uint64(1), stats.Trips)
+		assert.Equal(t, uint64(1), stats.Rejected)
+	})
+
+	t.Run("Client Errors Do Not Trip", func(t *testing.T) {
+		var changes []permify.CircuitStateChange
+		client, _ := newBreakerClient(false, &changes, mockStep{status: http.StatusBadRequest, body: `{"code":3}`})
+
+		for i := 0; i < 10; i++ {
+			_, err := client.CheckPermission(ctx, subject, entity, "view")
+			assert.NotErrorIs(t, err, permify.ErrCircuitOpen)
+		}
+		assert.Empty(t, changes)
+	})
+
+	t.Run("Half Open Probe Closes Circuit", func(t *testing.T) {
+		var changes []permify.CircuitStateChange
+		client, _ := newBreakerClient(false, &changes,
+			mockStep{status: http.StatusBadGateway},
+			mockStep{status: http.StatusBadGateway},
+			mockStep{status: http.StatusBadGateway},
+			mockStep{status: http.StatusBadGateway},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		for i := 0; i < 4; i++ {
+			_, _ = client.CheckPermission(ctx, subject, entity, "view")
+		}
+		time.Sleep(30 * time.Millisecond)
+
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.Len(t, changes, 3)
+		assert.Equal(t, permify.CircuitHalfOpen, changes[1].To)
+		assert.Equal(t, permify.CircuitClosed, changes[2].To)
+	})
+
+	t.Run("Failed Probe Reopens Circuit", func(t *testing.T) {
+		var changes []permify.CircuitStateChange
+		client, _ := newBreakerClient(false, &changes, mockStep{status: http.StatusGatewayTimeout})
+		for i := 0; i < 4; i++ {
+			_, _ = client.CheckPermission(ctx, subject, entity, "view")
+		}
+		time.Sleep(30 * time.Millisecond)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NotErrorIs(t, err, permify.ErrCircuitOpen)
+		_, err = client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrCircuitOpen)
+
+		stats := client.(permify.CircuitBreakerReporter).CircuitBreakerStats()["localhost:3476"]
+		assert.Equal(t, uint64(2), stats.Trips)
+	})
+
+	t.Run("Per Operation Breakers", func(t *testing.T) {
+		var changes []permify.CircuitStateChange
+		client, _ := newBreakerClient(true, &changes, mockStep{status: http.StatusInternalServerError})
+		for i := 0; i < 4; i++ {
+			_, _ = client.CheckPermission(ctx, subject, entity, "view")
+		}
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrCircuitOpen)
+
+		_, err = client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{
+			EntityType: "doc",
+			Permission: "read",
+			Subject:    subject,
+		})
+		assert.NotErrorIs(t, err, permify.ErrCircuitOpen)
+
+		stats := client.(permify.CircuitBreakerReporter).CircuitBreakerStats()
+		assert.Equal(t, permify.CircuitOpen, stats["localhost:3476/check"].State)
+		assert.Equal(t, permify.CircuitClosed, stats["localhost:3476/lookup"].State)
+	})
+
+	t.Run("Shared Config Left Unchanged", func(t *testing.T) {
+		breaker := &permify.CircuitBreakerConfig{MinRequests: 2}
+		withBreaker := func(config *permify.Config) {
+			config.CircuitBreaker = breaker
+		}
+		first, _ := newTestClient(withBreaker, mockStep{status: http.StatusServiceUnavailable})
+		second, _ := newTestClient(withBreaker, mockStep{status: http.StatusOK, body: allowed})
+		// defaults are not written back to the caller's config
+		assert.Equal(t, permify.CircuitBreakerConfig{MinRequests: 2}, *breaker)
+
+		for i := 0; i < 2; i++ {
+			_, _ = first.CheckPermission(ctx, subject, entity, "view")
+		}
+		_, err := first.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrCircuitOpen)
+		_, err = second.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+	})
+}
@@ -5,41 +5,166 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // CheckPermission verifies if a subject has a specific permission or role on
 // an entity. It returns true if the permission is granted and false otherwise.
-// If there's an issue during the check, an error is returned.
+// If there's an issue during the check, an error is returned. Degraded
+// decisions of the CheckFallbackPolicy are returned without an error, use
+// Check to tell them apart.
 func (c *client) CheckPermission(ctx context.Context, who *Subject, what *Entity, permission string) (bool, error) {
 	if err := c.validatePermissionCheckInput(who, what, permission); err != nil {
 		return false, err
 	}
 
-	request := PermissionCheckRequest{
-		Metadata: Metadata{
-			Depth: 100, // hard limit for now
-		},
+	decision, err := c.Check(ctx, &PermissionCheckRequest{
 		Entity:     what,
 		Permission: permission,
 		Subject:    who,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return decision.Allowed, nil
+}
+
+// CheckOption overrides part of a PermissionCheckRequest for one call.
+type CheckOption func(*PermissionCheckRequest)
+
+// CheckDepth limits the depth of the graph search.
+func CheckDepth(depth int) CheckOption {
+	return func(r *PermissionCheckRequest) {
+		r.Metadata.Depth = depth
+	}
+}
+
+// CheckConsistency evaluates the check at the non-empty fields of consistency.
+func CheckConsistency(consistency Consistency) CheckOption {
+	return func(r *PermissionCheckRequest) {
+		if consistency.SnapToken != "" {
+			r.Metadata.Snap = consistency.SnapToken
+		}
+		if consistency.SchemaVersion != "" {
+			r.Metadata.Schema = consistency.SchemaVersion
+		}
+		if consistency.Depth > 0 {
+			r.Metadata.Depth = consistency.Depth
+		}
+	}
+}
+
+// CheckContextualTuples adds relationships that only exist for this check,
+// e.g. the viewer relation granted by a share link.
+func CheckContextualTuples(tuples ...*Relationship) CheckOption {
+	return func(r *PermissionCheckRequest) {
+		r.Context = r.Context.clone()
+		r.Context.Tuples = append(r.Context.Tuples, tuples...)
+	}
+}
+
+// CheckContextAttributes adds attributes that only exist for this check,
+// e.g. the caller's IP address for an IP range rule.
+func CheckContextAttributes(attributes ...*Attribute) CheckOption {
+	return func(r *PermissionCheckRequest) {
+		r.Context = r.Context.clone()
+		r.Context.Attributes = append(r.Context.Attributes, attributes...)
+	}
+}
+
+// CheckContextData makes value available to the schema's rules under key.
+func CheckContextData(key string, value interface{}) CheckOption {
+	return func(r *PermissionCheckRequest) {
+		r.Context = r.Context.clone()
+		r.Context.Data[key] = value
 	}
+}
+
+// clone returns a copy of c the options can modify without touching the
+// caller's request, a nil c gives an empty Context.
+func (c *Context) clone() *Context {
+	out := &Context{Data: make(map[string]interface{})}
+	if c == nil {
+		return out
+	}
+	out.Tuples = append(out.Tuples, c.Tuples...)
+	out.Attributes = append(out.Attributes, c.Attributes...)
+	for key, value := range c.Data {
+		out.Data[key] = value
+	}
+	return out
+}
 
+// Check verifies a permission and returns the full decision. When Permify
+// is unavailable the configured CheckFallbackPolicy may answer instead, in
+// which case the decision is flagged as degraded. Options override the
+// request, metadata left empty is filled in from the context's Consistency
+// and the client's defaults.
+func (c *client) Check(ctx context.Context, request *PermissionCheckRequest, opts ...CheckOption) (*CheckDecision, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if err := c.validatePermissionCheckInput(request.Subject, request.Entity, request.Permission); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resolved := *request
+	for _, opt := range opts {
+		opt(&resolved)
+	}
+	if err := validateContext(resolved.Context); err != nil {
+		return nil, err
+	}
+	resolved.Metadata = c.metadata(ctx, resolved.Metadata)
+
+	// decisions made with request scoped data are not worth remembering
+	key := ""
+	if resolved.Context == nil {
+		key = decisionKey(c.config.Tenant, &resolved)
+	}
+	response, err := c.check(ctx, &resolved)
+	if err != nil {
+		if decision := c.fallback.decide(key, resolved.Permission, err); decision != nil {
+			decision.Latency = time.Since(start)
+			return decision, nil
+		}
+		return nil, err
+	}
+
+	allowed := response.IsAllowed()
+	if key != "" {
+		c.fallback.remember(key, allowed)
+	}
+	return &CheckDecision{
+		Allowed:       allowed,
+		CheckCount:    response.Metadata.CheckCount,
+		SnapToken:     resolved.Metadata.Snap,
+		SchemaVersion: resolved.Metadata.Schema,
+		Latency:       time.Since(start),
+		Source:        CheckSourceServer,
+	}, nil
+}
+
+// check asks the server for a decision.
+func (c *client) check(ctx context.Context, request *PermissionCheckRequest) (*PermissionCheckResponse, error) {
 	url := c.constructURL(PermissionCheckAPIPath)
-	body, err := c.sendRequest(ctx, http.MethodPost, url, request)
+	body, err := c.sendRequest(ctx, OperationCheck, http.MethodPost, url, request)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	var response PermissionCheckResponse
 	if err := json.Unmarshal(body, &response); err != nil {
-		return false, fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
-		return false, ErrUnableToCheckRelationship
+		return nil, responseError(OperationCheck, url, response.ErrorResponse)
 	}
 
-	return response.IsAllowed(), nil
+	return &response, nil
 }
 
 // validatePermissionCheckInput validates the inputs for the CheckPermission function.
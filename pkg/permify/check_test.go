@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/slimdevl/repro/pkg/permify"
 	"github.com/stretchr/testify/assert"
@@ -94,3 +95,63 @@ func TestCheckPermission(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 }
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "doc", Id: "doc1"}
+
+	staleFallback := func(config *permify.Config) {
+		config.CheckFallback = &permify.CheckFallbackPolicy{Mode: permify.FailStale, MaxStaleness: time.Minute}
+	}
+
+	t.Run("Decision Metadata", func(t *testing.T) {
+		client, rt := newTestClient(staleFallback, mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {"check_count": 3}}`})
+
+		decision, err := client.Check(ctx, &permify.PermissionCheckRequest{
+			Metadata:   permify.Metadata{Schema: "schema1"},
+			Entity:     entity,
+			Permission: "view",
+			Subject:    subject,
+		}, permify.CheckConsistency(permify.Consistency{SnapToken: "snap1"}), permify.CheckDepth(7))
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 3, decision.CheckCount)
+		assert.Equal(t, "snap1", decision.SnapToken)
+		assert.Equal(t, "schema1", decision.SchemaVersion)
+		assert.Positive(t, decision.Latency)
+		assert.False(t, decision.FromCache)
+		assert.Equal(t, permify.CheckSourceServer, decision.Source)
+		assert.Equal(t, permify.Metadata{Snap: "snap1", Schema: "schema1", Depth: 7}, sentMetadata(t, rt.requests[0]))
+	})
+
+	t.Run("Contextual Data", func(t *testing.T) {
+		client, rt := newTestClient(staleFallback,
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`},
+			mockStep{status: http.StatusServiceUnavailable},
+		)
+
+		request := &permify.PermissionCheckRequest{Entity: entity, Permission: "view", Subject: subject}
+		link := &permify.Relationship{Entity: entity, Relation: "viewer", Subject: &permify.Subject{Type: "link", Id: "abc"}}
+		_, err := client.Check(ctx, request,
+			permify.CheckContextualTuples(link),
+			permify.CheckContextData("ip", "10.0.0.1"))
+		assert.NoError(t, err)
+		assert.Nil(t, request.Context)
+
+		var sent struct {
+			Context struct {
+				Tuples []map[string]interface{} `json:"tuples"`
+				Data   map[string]interface{}   `json:"data"`
+			} `json:"context"`
+		}
+		decodeSent(t, rt.requests[0], &sent)
+		assert.Len(t, sent.Context.Tuples, 1)
+		assert.Equal(t, "viewer", sent.Context.Tuples[0]["relation"])
+		assert.Equal(t, map[string]interface{}{"ip": "10.0.0.1"}, sent.Context.Data)
+
+		// a decision made with request scoped data is not served from the stale cache
+		_, err = client.Check(ctx, request, permify.CheckContextualTuples(link))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,340 @@
+package permify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultCheckManyConcurrency = 8
+	DefaultBulkCheckSize        = 100 // most items Permify accepts in one bulk check
+)
+
+// ErrCheckSkipped is reported for items CheckMany did not check because the
+// outcome of the batch was already decided.
+var ErrCheckSkipped = errors.New("check skipped")
+
+// CheckItem is a single permission check of a CheckMany batch.
+type CheckItem struct {
+	Entity     *Entity
+	Permission string
+	Subject    *Subject
+}
+
+// CheckManyMode selects when CheckMany may stop early.
+type CheckManyMode int
+
+const (
+	CheckEvery      CheckManyMode = iota // Check every item
+	CheckAnyAllowed                      // Stop once an item is allowed
+	CheckAllAllowed                      // Stop once an item is denied or fails
+)
+
+// CheckManyOptions configures a CheckMany batch.
+type CheckManyOptions struct {
+	Mode CheckManyMode
+	// Concurrency bounds the checks in flight, all of them still pass the
+	// client's rate limiter. Defaults to DefaultCheckManyConcurrency.
+	Concurrency int
+	// BulkSize is the number of items sent per bulk check, defaults to
+	// DefaultBulkCheckSize.
+	BulkSize int
+	// DisableBulk checks every item on its own even if the server supports
+	// bulk checks.
+	DisableBulk bool
+	// CheckOptions apply to every item, e.g. a snap token or contextual tuples.
+	CheckOptions []CheckOption
+}
+
+// CheckResult is the outcome of one item of a CheckMany batch.
+type CheckResult struct {
+	Item     CheckItem
+	Decision *CheckDecision // nil when Err is set
+	Err      error          // ErrCheckSkipped for items left out by an early exit
+}
+
+// CheckManyResult is the outcome of a CheckMany batch.
+type CheckManyResult struct {
+	Results []CheckResult // in the order of the items
+	// Allowed tells whether any item was allowed for CheckAnyAllowed, or
+	// whether all items were allowed otherwise.
+	Allowed bool
+}
+
+// checkBatch is the state of a CheckMany call.
+type checkBatch struct {
+	client   *client
+	parent   context.Context // caller's context, to tell early exits from cancellation
+	cancel   context.CancelFunc
+	options  CheckManyOptions
+	template PermissionCheckRequest // carries the options shared by all items
+	items    []CheckItem
+	keys     []string
+	results  []CheckResult
+	stopped  atomic.Bool
+}
+
+// CheckMany checks many permissions at once. Identical items are checked
+// once, the results keep the order of the items and failures are reported
+// per item. Permify's bulk check endpoint is used when the server supports
+// it, otherwise the items are checked concurrently. The error is only set
+// when ctx ended before all items were checked.
+func (c *client) CheckMany(ctx context.Context, items []CheckItem, options *CheckManyOptions) (*CheckManyResult, error) {
+	b := &checkBatch{
+		client:  c,
+		parent:  ctx,
+		items:   items,
+		keys:    make([]string, len(items)),
+		results: make([]CheckResult, len(items)),
+	}
+	if options != nil {
+		b.options = *options
+	}
+	if b.options.Concurrency <= 0 {
+		b.options.Concurrency = DefaultCheckManyConcurrency
+	}
+	if b.options.BulkSize <= 0 {
+		b.options.BulkSize = DefaultBulkCheckSize
+	}
+	for _, opt := range b.options.CheckOptions {
+		opt(&b.template)
+	}
+	ctx, b.cancel = context.WithCancel(ctx)
+	defer b.cancel()
+
+	// only the first occurrence of an item is checked
+	owner := make([]int, len(items))
+	seen := make(map[string]int, len(items))
+	var pending []int
+	contextErr := validateContext(b.template.Context)
+	for i, item := range items {
+		owner[i] = i
+		b.results[i].Item = item
+		if contextErr != nil {
+			b.finish(i, nil, contextErr)
+			continue
+		}
+		if err := c.validatePermissionCheckInput(item.Subject, item.Entity, item.Permission); err != nil {
+			b.finish(i, nil, err)
+			continue
+		}
+		b.keys[i] = decisionKey(c.config.Tenant, item.request())
+		if first, ok := seen[b.keys[i]]; ok {
+			owner[i] = first
+			continue
+		}
+		seen[b.keys[i]] = i
+		pending = append(pending, i)
+	}
+
+	if b.options.DisableBulk || c.bulkUnsupported.Load() || !b.bulk(ctx, pending) {
+		b.fanOut(ctx, pending)
+	}
+
+	result := &CheckManyResult{Results: b.results, Allowed: b.options.Mode != CheckAnyAllowed}
+	for i := range result.Results {
+		if owner[i] != i {
+			result.Results[i].Decision = result.Results[owner[i]].Decision
+			result.Results[i].Err = result.Results[owner[i]].Err
+		}
+		allowed := result.Results[i].Decision != nil && result.Results[i].Decision.Allowed
+		if b.options.Mode == CheckAnyAllowed {
+			result.Allowed = result.Allowed || allowed
+		} else {
+			result.Allowed = result.Allowed && allowed
+		}
+	}
+	return result, b.parent.Err()
+}
+
+// request returns the check request of the item.
+func (item CheckItem) request() *PermissionCheckRequest {
+	return &PermissionCheckRequest{Entity: item.Entity, Permission: item.Permission, Subject: item.Subject}
+}
+
+// finish records the outcome of item i and stops the batch once its outcome
+// is decided.
+func (b *checkBatch) finish(i int, decision *CheckDecision, err error) {
+	if err != nil && b.stopped.Load() && b.parent.Err() == nil && errors.Is(err, context.Canceled) {
+		err = ErrCheckSkipped
+	}
+	b.results[i].Decision = decision
+	b.results[i].Err = err
+
+	switch {
+	case b.options.Mode == CheckAnyAllowed && decision != nil && decision.Allowed,
+		b.options.Mode == CheckAllAllowed && (decision == nil || !decision.Allowed):
+		b.stopped.Store(true)
+		b.cancel()
+	}
+}
+
+// fanOut checks the items one by one on a pool of workers.
+func (b *checkBatch) fanOut(ctx context.Context, pending []int) {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.options.Concurrency && w < len(pending); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if b.stopped.Load() {
+					b.finish(i, nil, ErrCheckSkipped)
+					continue
+				}
+				decision, err := b.client.Check(ctx, b.items[i].request(), b.options.CheckOptions...)
+				b.finish(i, decision, err)
+			}
+		}()
+	}
+	for _, i := range pending {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
+
+// bulk checks the items in chunks with the bulk check endpoint. It returns
+// false, without checking anything, when the server does not support it.
+func (b *checkBatch) bulk(ctx context.Context, pending []int) bool {
+	var chunks [][]int
+	for len(pending) > 0 {
+		n := b.options.BulkSize
+		if n > len(pending) {
+			n = len(pending)
+		}
+		chunks = append(chunks, pending[:n])
+		pending = pending[n:]
+	}
+	if len(chunks) == 0 {
+		return true
+	}
+
+	// the first chunk tells whether the server supports bulk checks
+	if err := b.bulkChunk(ctx, chunks[0]); err != nil {
+		if isUnimplemented(err) {
+			b.client.bulkUnsupported.Store(true)
+			return false
+		}
+		b.failChunk(chunks[0], err)
+	}
+
+	work := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.options.Concurrency && w < len(chunks)-1; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range work {
+				if err := b.bulkChunk(ctx, chunk); err != nil {
+					b.failChunk(chunk, err)
+				}
+			}
+		}()
+	}
+	for _, chunk := range chunks[1:] {
+		work <- chunk
+	}
+	close(work)
+	wg.Wait()
+	return true
+}
+
+// bulkChunk checks a chunk of items with one request. The items are left
+// untouched when it fails.
+func (b *checkBatch) bulkChunk(ctx context.Context, chunk []int) error {
+	if b.stopped.Load() {
+		for _, i := range chunk {
+			b.finish(i, nil, ErrCheckSkipped)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	request := &bulkCheckRequest{
+		Metadata: b.client.metadata(ctx, b.template.Metadata),
+		Items:    make([]*bulkCheckItem, 0, len(chunk)),
+		Context:  b.template.Context,
+	}
+	for _, i := range chunk {
+		item := b.items[i].request()
+		request.Items = append(request.Items, &bulkCheckItem{Entity: item.Entity, Permission: item.Permission, Subject: item.Subject})
+	}
+
+	url := b.client.constructURL(BulkCheckAPIPath)
+	body, err := b.client.sendRequest(ctx, OperationBulkCheck, http.MethodPost, url, request)
+	if err != nil {
+		return err
+	}
+
+	var response bulkCheckResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
+		return responseError(OperationBulkCheck, url, response.ErrorResponse)
+	}
+	if len(response.Results) != len(chunk) {
+		return fmt.Errorf("%w: %d results for %d items", ErrUnableToCheckRelationship, len(response.Results), len(chunk))
+	}
+
+	latency := time.Since(start)
+	for k, i := range chunk {
+		allowed := response.Results[k].IsAllowed()
+		if b.template.Context == nil {
+			b.client.fallback.remember(b.keys[i], allowed)
+		}
+		b.finish(i, &CheckDecision{
+			Allowed:       allowed,
+			CheckCount:    response.Results[k].Metadata.CheckCount,
+			SnapToken:     request.Metadata.Snap,
+			SchemaVersion: request.Metadata.Schema,
+			Latency:       latency,
+			Source:        CheckSourceServer,
+		}, nil)
+	}
+	return nil
+}
+
+// failChunk reports a failed bulk check for every item of the chunk, unless
+// the CheckFallbackPolicy can decide them.
+func (b *checkBatch) failChunk(chunk []int, err error) {
+	for _, i := range chunk {
+		key := b.keys[i]
+		if b.template.Context != nil {
+			key = ""
+		}
+		if decision := b.client.fallback.decide(key, b.items[i].Permission, err); decision != nil {
+			b.finish(i, decision, nil)
+			continue
+		}
+		b.finish(i, nil, err)
+	}
+}
+
+// isUnimplemented reports whether the server rejected a call because it does
+// not implement the endpoint, e.g. bulk checks on older Permify versions.
+// Those answer through the gateway's unmatched route handler with a bare
+// 404 "Not Found", unlike the 404s for unknown tenants or schemas, which
+// name the error.
+func isUnimplemented(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	const (
+		codeNotFound      = 5 // gRPC status codes
+		codeUnimplemented = 12
+	)
+	routeNotFound := apiErr.StatusCode == http.StatusNotFound && apiErr.Code == codeNotFound &&
+		apiErr.Message == http.StatusText(http.StatusNotFound) && len(apiErr.Details) == 0
+	return routeNotFound ||
+		apiErr.StatusCode == http.StatusNotImplemented ||
+		apiErr.Code == codeUnimplemented
+}
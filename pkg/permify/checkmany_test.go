@@ -0,0 +1,277 @@
+package permify_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Bodies of the 404s of Permify's gateway for a route it does not serve and
+// of Permify for a tenant that does not exist.
+const (
+	routeNotFound  = `{"code":5,"message":"Not Found","details":[]}`
+	tenantNotFound = `{"code":5,"message":"ERROR_CODE_TENANT_NOT_FOUND","details":[]}`
+)
+
+// PermissionServer answers checks and bulk checks from a set of allowed
+// "entity#permission@subject" keys, like a server without the subject
+// permission endpoint.
+type PermissionServer struct {
+	allowed map[string]bool
+	bulk    bool          // serve the bulk check endpoint
+	delay   time.Duration // time taken by each check
+	// notFound bulk checks and subject permission calls are answered as if
+	// the tenant did not exist
+	notFound int
+
+	mu            sync.Mutex
+	checks        int
+	bulkChecks    int
+	subjectChecks int
+	inFlight      int
+	maxInFlight   int
+}
+
+type checkPayload struct {
+	Entity     permify.Entity  `json:"entity"`
+	Permission string          `json:"permission"`
+	Subject    permify.Subject `json:"subject"`
+}
+
+func (p checkPayload) key() string {
+	return fmt.Sprintf("%s:%s#%s@%s:%s", p.Entity.Type, p.Entity.Id, p.Permission, p.Subject.Type, p.Subject.Id)
+}
+
+func (s *PermissionServer) can(p checkPayload) string {
+	if s.allowed[p.key()] {
+		return permify.CheckResponseAllowed
+	}
+	return "CHECK_RESULT_DENIED"
+}
+
+func (s *PermissionServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(s.delay):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	raw, _ := io.ReadAll(req.Body)
+	status, body := http.StatusOK, ""
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/permissions/bulk-check"):
+		s.mu.Lock()
+		s.bulkChecks++
+		notFound := s.notFound > 0
+		if notFound {
+			s.notFound--
+		}
+		s.mu.Unlock()
+		if notFound {
+			status, body = http.StatusNotFound, tenantNotFound
+			break
+		}
+		if !s.bulk {
+			status, body = http.StatusNotFound, routeNotFound
+			break
+		}
+		var payload struct {
+			Items []checkPayload `json:"items"`
+		}
+		_ = json.Unmarshal(raw, &payload)
+		results := make([]string, len(payload.Items))
+		for i, item := range payload.Items {
+			results[i] = fmt.Sprintf(`{"can": %q, "metadata": {"check_count": 1}}`, s.can(item))
+		}
+		body = `{"results": [` + strings.Join(results, ",") + `]}`
+	case strings.HasSuffix(req.URL.Path, "/permissions/subject-permission"):
+		s.mu.Lock()
+		s.subjectChecks++
+		notFound := s.notFound > 0
+		if notFound {
+			s.notFound--
+		}
+		s.mu.Unlock()
+		status, body = http.StatusNotFound, routeNotFound
+		if notFound {
+			status, body = http.StatusNotFound, tenantNotFound
+		}
+	default:
+		s.mu.Lock()
+		s.checks++
+		s.mu.Unlock()
+		var payload checkPayload
+		_ = json.Unmarshal(raw, &payload)
+		body = fmt.Sprintf(`{"can": %q, "metadata": {"check_count": 1}}`, s.can(payload))
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+}
+
+func TestCheckMany(t *testing.T) {
+	ctx := context.Background()
+	alice := &permify.Subject{Type: "user", Id: "alice"}
+	doc := func(id string) *permify.Entity {
+		return &permify.Entity{Type: "doc", Id: id}
+	}
+	items := func(ids ...string) []permify.CheckItem {
+		out := make([]permify.CheckItem, len(ids))
+		for i, id := range ids {
+			out[i] = permify.CheckItem{Entity: doc(id), Permission: "view", Subject: alice}
+		}
+		return out
+	}
+	newCheckManyClient := func(server *PermissionServer) permify.RelationshipClient {
+		config := permify.NewDefaultConfig()
+		config.Client = &http.Client{Transport: server}
+		config.RateLimit = 10000
+		config.Burst = 100
+		return permify.NewClient(config)
+	}
+	allowedDocs := func(ids ...string) map[string]bool {
+		allowed := make(map[string]bool)
+		for _, id := range ids {
+			allowed["doc:"+id+"#view@user:alice"] = true
+		}
+		return allowed
+	}
+
+	t.Run("Fan Out Keeps Order", func(t *testing.T) {
+		server := &PermissionServer{allowed: allowedDocs("d1", "d3", "d5"), delay: time.Millisecond}
+		client := newCheckManyClient(server)
+
+		result, err := client.CheckMany(ctx, items("d1", "d2", "d3", "d4", "d5", "d6"), &permify.CheckManyOptions{Concurrency: 3})
+		require.NoError(t, err)
+		require.Len(t, result.Results, 6)
+		for i, r := range result.Results {
+			assert.NoError(t, r.Err)
+			assert.Equal(t, fmt.Sprintf("d%d", i+1), r.Item.Entity.Id)
+			assert.Equal(t, i%2 == 0, r.Decision.Allowed, r.Item.Entity.Id)
+		}
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 6, server.checks)
+		assert.Equal(t, 1, server.bulkChecks)
+		assert.LessOrEqual(t, server.maxInFlight, 3)
+
+		// the missing bulk endpoint is remembered
+		_, err = client.CheckMany(ctx, items("d1"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, server.bulkChecks)
+	})
+
+	t.Run("Bulk", func(t *testing.T) {
+		server := &PermissionServer{allowed: allowedDocs("d1", "d2", "d3"), bulk: true}
+		client := newCheckManyClient(server)
+
+		result, err := client.CheckMany(ctx, items("d1", "d2", "d3"), &permify.CheckManyOptions{BulkSize: 2})
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		for _, r := range result.Results {
+			assert.True(t, r.Decision.Allowed)
+			assert.Equal(t, 1, r.Decision.CheckCount)
+		}
+		assert.Equal(t, 2, server.bulkChecks)
+		assert.Equal(t, 0, server.checks)
+	})
+
+	t.Run("Not Found Does Not Disable Bulk", func(t *testing.T) {
+		server := &PermissionServer{allowed: allowedDocs("d1"), bulk: true, notFound: 1}
+		client := newCheckManyClient(server)
+
+		result, err := client.CheckMany(ctx, items("d1"), nil)
+		require.NoError(t, err)
+		assert.Error(t, result.Results[0].Err)
+
+		result, err = client.CheckMany(ctx, items("d1"), nil)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, server.bulkChecks)
+		assert.Equal(t, 0, server.checks)
+	})
+
+	t.Run("Duplicates And Invalid Items", func(t *testing.T) {
+		server := &PermissionServer{allowed: allowedDocs("d1")}
+		client := newCheckManyClient(server)
+
+		batch := append(items("d1", "d2", "d1"), permify.CheckItem{Entity: doc(""), Permission: "view", Subject: alice})
+		result, err := client.CheckMany(ctx, batch, &permify.CheckManyOptions{DisableBulk: true})
+		require.NoError(t, err)
+		assert.Equal(t, 2, server.checks)
+		assert.True(t, result.Results[0].Decision.Allowed)
+		assert.False(t, result.Results[1].Decision.Allowed)
+		assert.True(t, result.Results[2].Decision.Allowed)
+		assert.Error(t, result.Results[3].Err)
+		assert.Nil(t, result.Results[3].Decision)
+		assert.Equal(t, 0, server.bulkChecks)
+	})
+
+	t.Run("Any Allowed Stops Early", func(t *testing.T) {
+		server := &PermissionServer{allowed: allowedDocs("d1"), delay: 5 * time.Millisecond}
+		client := newCheckManyClient(server)
+
+		result, err := client.CheckMany(ctx, items("d1", "d2", "d3", "d4", "d5", "d6"), &permify.CheckManyOptions{
+			Mode:        permify.CheckAnyAllowed,
+			Concurrency: 1,
+			DisableBulk: true,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.True(t, result.Results[0].Decision.Allowed)
+		for _, r := range result.Results[1:] {
+			assert.ErrorIs(t, r.Err, permify.ErrCheckSkipped)
+		}
+		assert.Equal(t, 1, server.checks)
+	})
+
+	t.Run("All Allowed Stops Early", func(t *testing.T) {
+		server := &PermissionServer{allowed: allowedDocs("d1", "d3"), bulk: true}
+		client := newCheckManyClient(server)
+
+		result, err := client.CheckMany(ctx, items("d1", "d2", "d3", "d4"), &permify.CheckManyOptions{
+			Mode:        permify.CheckAllAllowed,
+			Concurrency: 1,
+			BulkSize:    2,
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.False(t, result.Results[1].Decision.Allowed)
+		assert.ErrorIs(t, result.Results[2].Err, permify.ErrCheckSkipped)
+		assert.ErrorIs(t, result.Results[3].Err, permify.ErrCheckSkipped)
+		assert.Equal(t, 1, server.bulkChecks)
+	})
+
+	t.Run("Caller Cancels", func(t *testing.T) {
+		server := &PermissionServer{delay: time.Second}
+		client := newCheckManyClient(server)
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		result, err := client.CheckMany(ctx, items("d1", "d2"), &permify.CheckManyOptions{DisableBulk: true})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		for _, r := range result.Results {
+			assert.ErrorIs(t, r.Err, context.DeadlineExceeded)
+		}
+	})
+}
@@ -8,8 +8,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-
-	"golang.org/x/time/rate"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -17,13 +18,24 @@ var (
 	ErrUnableToDeleteRelationship = errors.New("failed to delete relationship")
 	ErrUnableToFindRelationships  = errors.New("failed to find relationships")
 	ErrUnableToLookupRelationship = errors.New("failed to lookup relationship")
+	ErrUnableToLookupSubject      = errors.New("failed to lookup subject")
 	ErrUnableToCheckRelationship  = errors.New("failed to check relationship")
+	ErrUnableToListPermissions    = errors.New("failed to list subject permissions")
 	ErrUnableToWriteSchema        = errors.New("failed to update model")
 	ErrUnableToCreateTenant       = errors.New("failed to create tenant")
 	ErrUnableToDeleteTenant       = errors.New("failed to delete tenant")
 	ErrUnableToListTenant         = errors.New("failed to list tenants")
+	ErrUnableToWriteData          = errors.New("failed to write data")
+	ErrUnableToReadAttributes     = errors.New("failed to read attributes")
+	ErrUnableToDeleteData         = errors.New("failed to delete data")
+	ErrUnableToReadRelationships  = errors.New("failed to read relationships")
 	ErrBodyDecodeFailure          = errors.New("failed to decode response body")
+	ErrRequestFailed              = errors.New("failed to send request")
 	ErrRateLimitExceeded          = errors.New("rate limit exceeded")
+	ErrRateLimitDeadline          = errors.New("rate limit deadline passes before a token is available")
+	ErrRateLimitWouldBlock        = errors.New("rate limit would block")
+	ErrRateLimitCancelled         = errors.New("rate limit wait cancelled")
+	ErrServerThrottled            = errors.New("server throttled the request")
 )
 
 const (
@@ -46,11 +58,20 @@ type RelationshipClient interface {
 	// is not found, an error is returned.
 	LookupRelationship(ctx context.Context, request *LookupRelationshipRequest) (*LookupRelationshipResponse, error)
 
+	// LookupSubject retrieves the IDs of the subjects of a type that have a
+	// permission on an entity, a page at a time. If the lookup fails, an
+	// error is returned.
+	LookupSubject(ctx context.Context, request *LookupSubjectRequest) (*LookupSubjectResponse, error)
+
 	// FindRelationships identifies all relationships associated with a given subject
 	// or entity. It returns a collection of relationships in the FoundRelationshipsResponse
 	// structure. If there's an issue during the process, an error is returned.
 	FindRelationships(ctx context.Context, request *FindRelationshipsRequest) (*FindRelationshipsResponse, error)
 
+	// Expand returns the full expansion of a permission on an entity, with
+	// the set operations, nested usersets and leaf subjects it is made of.
+	Expand(ctx context.Context, request *FindRelationshipsRequest) (*ExpandTree, error)
+
 	// DeleteRelationship removes a specific relationship based on the given criteria.
 	// If successful, it returns nil. If the deletion fails or the relationship
 	// isn't found, an error is returned.
@@ -60,6 +81,22 @@ type RelationshipClient interface {
 	// an entity. It returns true if the permission is granted and false otherwise.
 	// If there's an issue during the check, an error is returned.
 	CheckPermission(ctx context.Context, subject *Subject, entity *Entity, roleOrPermission string) (bool, error)
+
+	// Check verifies a permission and returns the full decision, options
+	// override the request. When Permify is unavailable the configured
+	// CheckFallbackPolicy may answer instead, in which case the decision is
+	// flagged as degraded.
+	Check(ctx context.Context, request *PermissionCheckRequest, opts ...CheckOption) (*CheckDecision, error)
+
+	// CheckMany checks many permissions at once and returns the results in
+	// the order of the items. Failures are reported per item, the error is
+	// only set when ctx ended before all items were checked.
+	CheckMany(ctx context.Context, items []CheckItem, options *CheckManyOptions) (*CheckManyResult, error)
+
+	// SubjectPermissions returns which permissions and relations of an entity
+	// a subject holds. When the server lacks the endpoint the request's
+	// Permissions are checked one by one instead.
+	SubjectPermissions(ctx context.Context, request *SubjectPermissionRequest) (*SubjectPermissionResponse, error)
 }
 
 // SchemaManagerClient represents the behavior of a client managing schemas.
@@ -79,13 +116,63 @@ type SchemaManagerClient interface {
 	SaveModelSchema(ctx context.Context, schema *SaveSchemaRequest) (*SaveSchemaResponse, error)
 }
 
+// DataClient represents the behavior of a client managing the attributes
+// Permify's ABAC rules are evaluated on, together with relationships.
+type DataClient interface {
+	// WriteData writes relationships and attributes in one request and
+	// returns the snapshot of the write.
+	WriteData(ctx context.Context, request *WriteDataRequest) (*RelationshipSnap, error)
+
+	// ReadAttributes returns a page of the attributes matching the filter.
+	ReadAttributes(ctx context.Context, request *ReadAttributesRequest) (*ReadAttributesResponse, error)
+
+	// DeleteData deletes the relationships and attributes matching the
+	// filters and returns the snapshot of the deletion.
+	DeleteData(ctx context.Context, request *DeleteDataRequest) (*RelationshipSnap, error)
+
+	// ReadRelationships returns an iterator over the stored relationships
+	// matching the filter, fetched pageSize at a time.
+	ReadRelationships(ctx context.Context, filter RelationshipFilter, pageSize int) *RelationshipIterator
+
+	// ReadRelationshipsPage returns a page of the stored relationships
+	// matching the filter. The response's ContinuousToken is set when there
+	// are more pages.
+	ReadRelationshipsPage(ctx context.Context, request *ReadRelationshipsRequest) (*ReadRelationshipsResponse, error)
+
+	// WalkRelationships calls fn for each stored relationship matching the
+	// filter until fn returns an error, ctx ends or there are no more.
+	WalkRelationships(ctx context.Context, filter RelationshipFilter, pageSize int, fn func(*Relationship) error) error
+}
+
 var _ RelationshipClient = (*client)(nil)
 var _ SchemaManagerClient = (*client)(nil)
+var _ DataClient = (*client)(nil)
+var _ RateLimitReporter = (*client)(nil)
+var _ CircuitBreakerReporter = (*client)(nil)
+var _ ConsistencyReporter = (*client)(nil)
+var _ SessionClient = (*client)(nil)
 
 type client struct {
-	config  *Config      // Client configuration
-	client  *http.Client // HTTP client for making requests
-	limiter *rate.Limiter
+	config   *Config      // Client configuration
+	client   *http.Client // HTTP client for making requests
+	global   *bucket      // Limiter shared by all operations without their own bucket
+	buckets  map[Bucket]*bucket
+	adaptive *adaptiveLimiter // nil unless adaptive rate limiting is enabled
+	fallback *checkFallback   // nil when checks fail closed
+	handler  Handler          // interceptor chain ending in send
+
+	breaker    *CircuitBreakerConfig // resolved copy of config.CircuitBreaker, nil disables the breakers
+	breakersMu sync.Mutex
+	breakers   map[string]*circuitBreaker
+
+	snapMu    sync.Mutex
+	snapToken string // snap token of the latest write, see ReadYourWrites
+
+	schemaMu sync.Mutex
+	schema   string // schema last saved through the client, see Explain
+
+	bulkUnsupported              atomic.Bool // set once the server rejected a bulk check
+	subjectPermissionUnsupported atomic.Bool // set once the server reported the subject permission endpoint unimplemented
 }
 
 // Config defines the configuration parameters for the client.
@@ -96,7 +183,51 @@ type Config struct {
 	APIKey     string       // Authentication or API key
 	Tenant     string       // Tenant identifier
 	Client     *http.Client // useful for mocking
-	RateLimit  int
+	RateLimit  int          // Requests per second, the starting rate when adaptive
+	Burst      int          // Burst of the global limiter, defaults to DefaultBurst
+	Retry      *RetryPolicy // Retry policy, nil disables retries
+	// AdaptiveRateLimit lets the rate limit follow the server's capacity,
+	// nil keeps the fixed RateLimit.
+	AdaptiveRateLimit *AdaptiveRateLimit
+	// BucketLimits gives operation classes their own budget, so e.g. writes
+	// cannot starve checks. Classes without an entry share the global limiter.
+	BucketLimits map[Bucket]BucketLimit
+	// GlobalCeiling makes calls with their own bucket pass the global
+	// limiter as well, capping the total rate at RateLimit.
+	GlobalCeiling bool
+	// OnRateLimitWait is called after every bucket a call waited on.
+	OnRateLimitWait func(RateLimitWait)
+	// Metrics records every call when set, see NewMetrics.
+	Metrics *Metrics
+	// Interceptors wrap every call, the first one is the outermost.
+	Interceptors []Interceptor
+	// ReadYourWrites makes reads that pin no snapshot see at least the
+	// latest write or delete made through this client.
+	ReadYourWrites bool
+	// Tracer creates a span per call, nil uses NoopTracer. The span context
+	// is sent to Permify in the traceparent header.
+	Tracer Tracer
+	// CheckFallback decides checks while Permify is unavailable, nil fails closed.
+	CheckFallback *CheckFallbackPolicy
+	// CircuitBreaker stops calls to a failing server, nil disables it.
+	CircuitBreaker *CircuitBreakerConfig
+	// NonBlocking makes calls fail with ErrRateLimitWouldBlock instead of
+	// queuing when no token is available, see also WithoutWaiting.
+	NonBlocking bool
+	// Authenticator sets credentials on each request. When nil and APIKey is
+	// set, the APIKey is sent as a static bearer token.
+	Authenticator Authenticator
+	// IDCodec translates entity and subject IDs to the alphabet Permify
+	// allows, nil uses EscapeIDCodec, which keeps every ID intact. It writes
+	// IDs with '-', '_' or other characters differently than the legacy
+	// SeparatorIDCodec did, e.g. a-b as a--b, and fails to read some IDs
+	// written by it. Upgrade a tenant by converting it with
+	// MigrateIDs(ctx, config, SeparatorIDCodec{}, EscapeIDCodec{}, types...)
+	// or keep SeparatorIDCodec for it.
+	IDCodec IDCodec
+	// Schema is the schema Explain quotes rules from, empty uses the schema
+	// last saved through the client.
+	Schema string
 }
 
 // NewDefaultConfig returns a default configuration for the client.
@@ -132,12 +263,44 @@ func NewClient(config *Config) RelationshipClient {
 	if config.RateLimit <= 0 {
 		config.RateLimit = DefaultRateLimit
 	}
+	if config.Tracer == nil {
+		config.Tracer = NoopTracer{}
+	}
+	if config.IDCodec == nil {
+		config.IDCodec = EscapeIDCodec{}
+	}
+	if config.Authenticator == nil && config.APIKey != "" {
+		config.Authenticator = NewStaticTokenAuthenticator(config.APIKey)
+	}
+
+	// the caller's breaker config may be shared, defaults go on a copy
+	var breaker *CircuitBreakerConfig
+	if config.CircuitBreaker != nil {
+		resolved := *config.CircuitBreaker
+		resolved.setDefaults()
+		breaker = &resolved
+	}
 
-	return &client{
-		config:  config,
-		client:  config.Client,
-		limiter: rate.NewLimiter(rate.Limit(config.RateLimit), 1),
+	c := &client{
+		config:   config,
+		client:   config.Client,
+		global:   newBucket(BucketGlobal, float64(config.RateLimit), config.Burst),
+		buckets:  make(map[Bucket]*bucket, len(config.BucketLimits)),
+		breaker:  breaker,
+		breakers: make(map[string]*circuitBreaker),
+		fallback: newCheckFallback(config.CheckFallback),
 	}
+	c.handler = chain(config.Interceptors, c.send)
+	for name, limit := range config.BucketLimits {
+		if name == BucketGlobal {
+			continue
+		}
+		c.buckets[name] = newBucket(name, limit.Rate, limit.Burst)
+	}
+	if config.AdaptiveRateLimit != nil {
+		c.adaptive = newAdaptiveLimiter(c.global.limiter, *config.AdaptiveRateLimit)
+	}
+	return c
 }
 
 // constructURL constructs the API endpoint URL based on the client's config and the provided path format.
@@ -147,44 +310,162 @@ func (c *client) constructURL(pathFormat string) string {
 
 // sendRequest sends a JSON request and returns the response body.
 // this is the central point where all APIs make their requests.
-// and we ratelimit
-func (c *client) sendRequest(ctx context.Context, method, url string, payload interface{}) ([]byte, error) {
-
-	if err := c.limiter.Wait(ctx); err != nil {
-		return nil, ErrRateLimitExceeded
-	}
-
-	// good to proceed
+// the request passes the interceptors first, then we ratelimit and
+// retry according to the configured policy.
+// Non 2xx responses are returned as an *APIError.
+func (c *client) sendRequest(ctx context.Context, op Operation, method, url string, payload interface{}) ([]byte, error) {
 	var requestBody []byte
 	var err error
 
-	// Only marshal and set the request body if the payload is not nil
+	// Only marshal and set the request body if the payload is not nil.
+	// The payload is marshalled once and reused for every attempt.
 	if payload != nil {
 		requestBody, err = json.Marshal(payload)
+		if err == nil {
+			requestBody, err = transcodeIDs(requestBody, encoder(c.config.IDCodec))
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request: %w", err)
 		}
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
+	req := &Request{
+		Operation: op,
+		Tenant:    c.config.Tenant,
+		Method:    method,
+		URL:       url,
+		Payload:   payload,
+		Body:      requestBody,
+		Header:    make(http.Header),
+	}
+	ctx, span := c.startSpan(ctx, req)
+
+	start := time.Now()
+	resp, err := c.handler(ctx, req)
+	c.config.Metrics.observeRequest(op, time.Since(start), err)
+	endSpan(span, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	body, err := transcodeIDs(resp.Body, decoder(c.config.IDCodec))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBodyDecodeFailure, err)
+	}
+	return body, nil
+}
+
+// send is the innermost Handler of the interceptor chain. It performs the
+// attempts of a request and returns the last response, together with an
+// *APIError when its status is not 2xx.
+func (c *client) send(ctx context.Context, req *Request) (*Response, error) {
+	policy := c.config.Retry
+	attempts := policy.attempts(req.Operation)
+	refreshed := false
+	for i, n := 1, 1; ; i, n = i+1, n+1 {
+		result, err := c.attempt(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		c.adaptive.observe(result.status, result.err)
+
+		// the server rejected our credentials before handling the call, so
+		// refresh them once and repeat the attempt regardless of the policy
+		if result.status == http.StatusUnauthorized && !refreshed && c.config.Authenticator != nil {
+			refreshed = true
+			if err := c.config.Authenticator.Refresh(ctx); err != nil {
+				return nil, fmt.Errorf("failed to refresh credentials: %w", err)
+			}
+			i--
+			continue
+		}
+
+		retry := result.err != nil || policy.retryableStatus(result.status)
+		if !retry || i >= attempts || ctx.Err() != nil ||
+			!sleepContext(ctx, policy.backoff(i, result.retryAfter)) {
+			if result.err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrRequestFailed, result.err)
+			}
+			resp := &Response{
+				StatusCode: result.status,
+				Header:     result.header,
+				Body:       result.body,
+				Attempts:   n,
+			}
+			if result.status < 200 || result.status >= 300 {
+				return resp, newAPIError(req.Operation, req.URL, result.status, result.body)
+			}
+			return resp, nil
+		}
+		c.config.Metrics.observeRetry(req.Operation)
+	}
+}
+
+// attemptResult is the outcome of a single round trip to the server.
+type attemptResult struct {
+	body       []byte
+	status     int
+	header     http.Header
+	retryAfter time.Duration
+	err        error // transport error, eligible for a retry
+}
+
+// attempt performs a single round trip guarded by the circuit breaker and the
+// rate limiter. Errors that must not be retried are returned directly,
+// transport errors are carried in the result.
+func (c *client) attempt(ctx context.Context, r *Request) (*attemptResult, error) {
+	breaker := c.breakerFor(r.Operation)
+	if err := breaker.allow(); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx, r.Operation); err != nil {
+		breaker.cancel()
+		return nil, err
+	}
+
+	// good to proceed
+	result, err := c.roundTrip(ctx, r)
+	if err != nil || ctx.Err() != nil {
+		// the server was never asked, or the caller gave up on it
+		breaker.cancel()
+		return result, err
+	}
+	breaker.record(result.err != nil || isThrottled(result.status, nil))
+	return result, nil
+}
+
+// roundTrip sends the request and reads the response.
+func (c *client) roundTrip(ctx context.Context, r *Request) (*attemptResult, error) {
+	req, err := http.NewRequest(r.Method, r.URL, bytes.NewReader(r.Body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request: %w", err)
 	}
 	req = req.WithContext(ctx)
-	req.Header.Add(ContentTypeHeader, ContentTypeJSON)
+	for key, values := range r.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.Header.Set(ContentTypeHeader, ContentTypeJSON)
 
-	// TODO: Set authentication header
+	if c.config.Authenticator != nil {
+		if err := c.config.Authenticator.Authenticate(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to authenticate request: %w", err)
+		}
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return &attemptResult{err: err}, nil
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return &attemptResult{err: fmt.Errorf("failed to read response body: %w", err)}, nil
 	}
 
-	return respBody, nil
+	return &attemptResult{
+		body:       respBody,
+		status:     resp.StatusCode,
+		header:     resp.Header,
+		retryAfter: parseRetryAfter(resp.Header.Get(RetryAfterHeader)),
+	}, nil
 }
@@ -1,210 +1,222 @@
 package permify
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 )
 
-// Permify does not allow IDs to have values used in basic regex
-// so org.1224 must transition to org_1234, then back out.
-// the simplest way to do this is json custom marshalling.
-// this way it is transparent to our application and just works.
+// Permify only allows letters, digits, '_' and '-' in IDs, so IDs like
+// org.1224 are translated by an IDCodec on the way to Permify and back. The
+// client applies the codec to the JSON it sends and receives, which keeps it
+// transparent to our application and leaves the caller's structs untouched.
 
 const DecodedSeparator = "."
 const EncodedSeparator = "_"
 
-// encodeID replaces DecodedSeparator with EncodedSeparator
-func encodeID(input string) string {
-	return strings.ReplaceAll(input, DecodedSeparator, EncodedSeparator)
-}
+// MaxIDLength is the longest encoded ID Permify accepts.
+const MaxIDLength = 128
 
-// decodeID replaces EncodedSeparator with DecodedSeparator
-func decodeID(input string) string {
-	return strings.ReplaceAll(input, EncodedSeparator, DecodedSeparator)
-}
+// WildcardID stands for all subjects of a type and is never encoded.
+const WildcardID = "*"
 
-// encodeIDs replaces DecodedSeparator with EncodedSeparator in a slice of strings
-func encodeIDs(ids []string) []string {
-	encodedIDs := make([]string, len(ids))
-	for i, id := range ids {
-		encodedIDs[i] = strings.ReplaceAll(id, DecodedSeparator, EncodedSeparator)
-	}
-	return encodedIDs
-}
+var ErrInvalidID = errors.New("invalid id")
 
-// decodeIDs replaces EncodedSeparator with DecodedSeparator in a slice of strings
-func decodeIDs(ids []string) []string {
-	decodedIDs := make([]string, len(ids))
-	for i, id := range ids {
-		decodedIDs[i] = strings.ReplaceAll(id, EncodedSeparator, DecodedSeparator)
-	}
-	return decodedIDs
-}
+// encodedIDPattern is the alphabet Permify allows in IDs.
+var encodedIDPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
 
-func (e *Entity) MarshalJSON() ([]byte, error) {
-	e.Id = encodeID(e.Id)
-	type Alias Entity
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	})
+// IDCodec translates IDs to the alphabet Permify allows and back. Decode must
+// reverse Encode, IDs that would not round trip are rejected before a
+// request is sent.
+type IDCodec interface {
+	Encode(id string) (string, error)
+	Decode(id string) (string, error)
 }
 
-func (e *Entity) UnmarshalJSON(data []byte) error {
-	type Alias Entity
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
+// SeparatorIDCodec is the legacy codec, it replaces DecodedSeparator with
+// EncodedSeparator and back. IDs that already contain EncodedSeparator
+// cannot round trip and are rejected.
+type SeparatorIDCodec struct{}
+
+func (SeparatorIDCodec) Encode(id string) (string, error) {
+	if strings.Contains(id, EncodedSeparator) {
+		return "", fmt.Errorf("%w: %q contains %q", ErrInvalidID, id, EncodedSeparator)
 	}
-	e.Id = decodeID(e.Id)
-	return nil
+	return encodeID(id), nil
 }
 
-func (s *Subject) MarshalJSON() ([]byte, error) {
-	s.Id = encodeID(s.Id)
-	type Alias Subject
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(s),
-	})
+func (SeparatorIDCodec) Decode(id string) (string, error) {
+	return decodeID(id), nil
 }
 
-func (s *Subject) UnmarshalJSON(data []byte) error {
-	type Alias Subject
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(s),
-	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
-	}
-	s.Id = decodeID(s.Id)
-	return nil
-}
+// EscapeIDCodec is the default, lossless codec. Like SeparatorIDCodec it
+// writes DecodedSeparator as EncodedSeparator, so IDs made of letters,
+// digits and dots are encoded the same way by both. Other characters are
+// escaped with '-': "--" for '-', "-_" for '_' and '-' followed by two hex
+// digits for any other byte. IDs written by SeparatorIDCodec that contain
+// '-' or '_' do not read back the same, see MigrateIDs.
+type EscapeIDCodec struct{}
 
-func (l *leaf) MarshalJSON() ([]byte, error) {
-	l.Id = encodeID(l.Id)
-	type Alias leaf
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(l),
-	})
+func (EscapeIDCodec) Encode(id string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b.WriteByte(c)
+		case c == DecodedSeparator[0]:
+			b.WriteString(EncodedSeparator)
+		case c == '-':
+			b.WriteString("--")
+		case c == '_':
+			b.WriteString("-_")
+		default:
+			fmt.Fprintf(&b, "-%02x", c)
+		}
+	}
+	return b.String(), nil
 }
 
-func (l *leaf) UnmarshalJSON(data []byte) error {
-	type Alias leaf
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(l),
+func (EscapeIDCodec) Decode(id string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c == EncodedSeparator[0]:
+			b.WriteString(DecodedSeparator)
+		case c != '-':
+			b.WriteByte(c)
+		case i+1 < len(id) && (id[i+1] == '-' || id[i+1] == '_'):
+			b.WriteByte(id[i+1])
+			i++
+		case i+2 < len(id) && isLowerHex(id[i+1]) && isLowerHex(id[i+2]):
+			b.WriteByte(unhex(id[i+1])<<4 | unhex(id[i+2]))
+			i += 2
+		default:
+			return "", fmt.Errorf("%w: %q has an invalid escape at %d", ErrInvalidID, id, i)
+		}
 	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
-	}
-	l.Id = decodeID(l.Id)
-	return nil
+	return b.String(), nil
 }
 
-func (e *EntityIDSet) MarshalJSON() ([]byte, error) {
-	e.Ids = encodeIDs(e.Ids)
-	type Alias EntityIDSet
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	})
+func isLowerHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f'
 }
 
-func (e *EntityIDSet) UnmarshalJSON(data []byte) error {
-	type Alias EntityIDSet
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
+func unhex(c byte) byte {
+	if c <= '9' {
+		return c - '0'
 	}
-	e.Ids = decodeIDs(e.Ids)
-	return nil
-}
-
-func (e *LookupRelationshipRequest) MarshalJSON() ([]byte, error) {
-	e.Subject.Id = encodeID(e.Subject.Id)
-	type Alias LookupRelationshipRequest
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	})
+	return c - 'a' + 10
 }
 
-func (e *LookupRelationshipRequest) UnmarshalJSON(data []byte) error {
-	type Alias LookupRelationshipRequest
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
-	}
-	e.Subject.Id = decodeID(e.Subject.Id)
-	return nil
+// encodeID replaces DecodedSeparator with EncodedSeparator
+func encodeID(input string) string {
+	return strings.ReplaceAll(input, DecodedSeparator, EncodedSeparator)
 }
 
-func (e *LookupRelationshipResponse) MarshalJSON() ([]byte, error) {
-	e.EntityIDs = encodeIDs(e.EntityIDs)
-	type Alias LookupRelationshipResponse
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
-	})
+// decodeID replaces EncodedSeparator with DecodedSeparator
+func decodeID(input string) string {
+	return strings.ReplaceAll(input, EncodedSeparator, DecodedSeparator)
 }
 
-func (e *LookupRelationshipResponse) UnmarshalJSON(data []byte) error {
-	type Alias LookupRelationshipResponse
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(e),
+// encoder returns a function encoding IDs with codec that rejects IDs which
+// would not round trip or that Permify would not accept.
+func encoder(codec IDCodec) func(string) (string, error) {
+	return func(id string) (string, error) {
+		if id == "" || id == WildcardID {
+			return id, nil
+		}
+		encoded, err := codec.Encode(id)
+		if err != nil {
+			return "", err
+		}
+		if decoded, err := codec.Decode(encoded); err != nil || decoded != id {
+			return "", fmt.Errorf("%w: %q does not round trip", ErrInvalidID, id)
+		}
+		if len(encoded) > MaxIDLength || !encodedIDPattern.MatchString(encoded) {
+			return "", fmt.Errorf("%w: %q encodes to %q, which Permify does not accept", ErrInvalidID, id, encoded)
+		}
+		return encoded, nil
+	}
+}
+
+// decoder returns a function decoding IDs with codec.
+func decoder(codec IDCodec) func(string) (string, error) {
+	return func(id string) (string, error) {
+		if id == "" || id == WildcardID {
+			return id, nil
+		}
+		return codec.Decode(id)
+	}
+}
+
+// idListKeys are the keys of plain lists of IDs.
+var idListKeys = map[string]bool{"entity_ids": true, "subject_ids": true}
+
+// transcodeIDs applies fn to every ID of a JSON document: the id of objects
+// naming an entity or subject, the ids of ID sets and the lists of IDs
+// returned by lookups. Free form data is left alone, as are bodies that are
+// not JSON.
+func transcodeIDs(body []byte, fn func(string) (string, error)) ([]byte, error) {
+	if len(body) == 0 || !json.Valid(body) {
+		return body, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if err := mapIDs(value, fn); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func mapIDs(value interface{}, fn func(string) (string, error)) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		_, named := v["type"].(string)
+		for key, child := range v {
+			var err error
+			switch {
+			case key == "data":
+				// context data and attribute values are the caller's own
+			case named && key == "id":
+				if id, ok := child.(string); ok {
+					v[key], err = fn(id)
+				}
+			case named && key == "ids", idListKeys[key]:
+				err = mapIDList(child, fn)
+			default:
+				err = mapIDs(child, fn)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if err := mapIDs(child, fn); err != nil {
+				return err
+			}
+		}
 	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
-	}
-	e.EntityIDs = decodeIDs(e.EntityIDs)
 	return nil
 }
 
-func (s *SubjectIDSet) MarshalJSON() ([]byte, error) {
-	s.Ids = encodeIDs(s.Ids)
-	type Alias SubjectIDSet
-	return json.Marshal(&struct {
-		*Alias
-	}{
-		Alias: (*Alias)(s),
-	})
-}
-
-func (s *SubjectIDSet) UnmarshalJSON(data []byte) error {
-	type Alias SubjectIDSet
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(s),
-	}
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
+func mapIDList(value interface{}, fn func(string) (string, error)) error {
+	ids, _ := value.([]interface{})
+	for i, child := range ids {
+		if id, ok := child.(string); ok {
+			mapped, err := fn(id)
+			if err != nil {
+				return err
+			}
+			ids[i] = mapped
+		}
 	}
-	s.Ids = decodeIDs(s.Ids)
 	return nil
 }
@@ -1,14 +1,11 @@
 package permify
 
 import (
-	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 )
 
-const (
-	expectedOrgEncoding = `{"type":"type","id":"org_1224"}`
-)
-
 func TestEncodeID(t *testing.T) {
 	input := "org.1224"
 	expected := "org_1224"
@@ -29,237 +26,123 @@ func TestDecodeID(t *testing.T) {
 	}
 }
 
-func TestEncodeIDs(t *testing.T) {
-	input := []string{"org.1224", "abc.5678"}
-	expected := []string{"org_1224", "abc_5678"}
-	result := encodeIDs(input)
-
-	if len(result) != len(expected) {
-		t.Errorf("Expected length: %d, Got length: %d", len(expected), len(result))
+func TestEscapeIDCodec(t *testing.T) {
+	tests := map[string]string{
+		"org.1224":      "org_1224",
+		"user_name.1":   "user-_name_1",
+		"a-b":           "a--b",
+		"me@example.io": "me-40example_io",
+		"é":             "-c3-a9",
 	}
-
-	for i, r := range result {
-		if r != expected[i] {
-			t.Errorf("Expected: %s, Got: %s", expected[i], r)
+	codec := EscapeIDCodec{}
+	for id, expected := range tests {
+		encoded, err := codec.Encode(id)
+		if err != nil || encoded != expected {
+			t.Errorf("Encode(%q): expected %q, got %q (%v)", id, expected, encoded, err)
 		}
-	}
-}
-
-func TestDecodeIDs(t *testing.T) {
-	input := []string{"org_1224", "abc_5678"}
-	expected := []string{"org.1224", "abc.5678"}
-	result := decodeIDs(input)
-
-	if len(result) != len(expected) {
-		t.Errorf("Expected length: %d, Got length: %d", len(expected), len(result))
-	}
-
-	for i, r := range result {
-		if r != expected[i] {
-			t.Errorf("Expected: %s, Got: %s", expected[i], r)
+		decoded, err := codec.Decode(encoded)
+		if err != nil || decoded != id {
+			t.Errorf("Decode(%q): expected %q, got %q (%v)", encoded, id, decoded, err)
 		}
 	}
-}
-
-func TestEntityMarshalJSON(t *testing.T) {
-	entity := &Entity{Type: "type", Id: "org.1224"}
-	expected := expectedOrgEncoding
-	result, err := json.Marshal(entity)
-
-	if err != nil {
-		t.Errorf("Error marshalling JSON: %v", err)
-	}
-
-	if string(result) != expected {
-		t.Errorf("Expected: %s, Got: %s", expected, string(result))
-	}
-}
-
-func TestEntityUnmarshalJSON(t *testing.T) {
-	jsonStr := expectedOrgEncoding
-	expected := &Entity{Type: "type", Id: "org.1224"}
-	var entity Entity
-	err := json.Unmarshal([]byte(jsonStr), &entity)
-
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
-
-	if entity.Type != expected.Type || entity.Id != expected.Id {
-		t.Errorf("Expected: %+v, Got: %+v", expected, entity)
-	}
-}
-
-func TestSubjectMarshalJSON(t *testing.T) {
-	subject := &Subject{Type: "type", Id: "org.1224"}
-	expected := expectedOrgEncoding
-	result, err := json.Marshal(subject)
-
-	if err != nil {
-		t.Errorf("Error marshalling JSON: %v", err)
-	}
-
-	if string(result) != expected {
-		t.Errorf("Expected: %s, Got: %s", expected, string(result))
-	}
-}
-
-func TestSubjectUnmarshalJSON(t *testing.T) {
-	jsonStr := expectedOrgEncoding
-	expected := &Subject{Type: "type", Id: "org.1224"}
-	var subject Subject
-	err := json.Unmarshal([]byte(jsonStr), &subject)
-
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
-
-	if subject.Type != expected.Type || subject.Id != expected.Id {
-		t.Errorf("Expected: %+v, Got: %+v", expected, subject)
-	}
-}
-
-func TestLeafMarshalJSON(t *testing.T) {
-	leaf := &leaf{Type: "type", Id: "org.1224", Relation: "rel"}
-	expected := `{"type":"type","id":"org_1224","relation":"rel"}`
-	result, err := json.Marshal(leaf)
-
-	if err != nil {
-		t.Errorf("Error marshalling JSON: %v", err)
-	}
-
-	if string(result) != expected {
-		t.Errorf("Expected: %s, Got: %s", expected, string(result))
-	}
-}
-
-func TestLeafUnmarshalJSON(t *testing.T) {
-	jsonStr := `{"type":"type","id":"org_1224","relation":"rel"}`
-	expected := &leaf{Type: "type", Id: "org.1224", Relation: "rel"}
-	var l leaf
-	err := json.Unmarshal([]byte(jsonStr), &l)
 
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
-
-	if l.Type != expected.Type || l.Id != expected.Id || l.Relation != expected.Relation {
-		t.Errorf("Expected: %+v, Got: %+v", expected, l)
-	}
-}
-
-func TestEntityIDSetMarshalJSON(t *testing.T) {
-	entityIDSet := &EntityIDSet{Type: "type", Ids: []string{"org.1224", "abc.5678"}}
-	expected := `{"type":"type","ids":["org_1224","abc_5678"]}`
-	result, err := json.Marshal(entityIDSet)
-
-	if err != nil {
-		t.Errorf("Error marshalling JSON: %v", err)
-	}
-
-	if string(result) != expected {
-		t.Errorf("Expected: %s, Got: %s", expected, string(result))
-	}
-}
-
-func TestEntityIDSetUnmarshalJSON(t *testing.T) {
-	jsonStr := `{"type":"type","ids":["org_1224","abc_5678"]}`
-	expected := &EntityIDSet{Type: "type", Ids: []string{"org.1224", "abc.5678"}}
-	var entityIDSet EntityIDSet
-	err := json.Unmarshal([]byte(jsonStr), &entityIDSet)
-
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
-
-	if entityIDSet.Type != expected.Type || len(entityIDSet.Ids) != len(expected.Ids) {
-		t.Errorf("Expected: %+v, Got: %+v", expected, entityIDSet)
-	}
-	for i, id := range entityIDSet.Ids {
-		if id != expected.Ids[i] {
-			t.Errorf("Expected: %s, Got: %s", expected.Ids[i], id)
+	for _, invalid := range []string{"a-", "a-x1", "a-4", "a-4G"} {
+		if _, err := codec.Decode(invalid); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Decode(%q): expected ErrInvalidID, got %v", invalid, err)
 		}
 	}
 }
 
-func TestLookupRelationshipResponseMarshalJSON(t *testing.T) {
-	lookupResponse := &LookupRelationshipResponse{
-		ErrorResponse: &ErrorResponse{Code: 404, Message: "Not Found", Details: []string{"Detail1", "Detail2"}},
-		EntityIDs:     []string{"org.1224", "abc.5678"},
-	}
-	expected := `{"code":404,"message":"Not Found","details":["Detail1","Detail2"],"entity_ids":["org_1224","abc_5678"]}`
-	result, err := json.Marshal(lookupResponse)
-
-	if err != nil {
-		t.Errorf("Error marshalling JSON: %v", err)
+func TestSeparatorIDCodec(t *testing.T) {
+	codec := SeparatorIDCodec{}
+	encoded, err := codec.Encode("org.1224")
+	if err != nil || encoded != "org_1224" {
+		t.Errorf("Expected: org_1224, Got: %s (%v)", encoded, err)
 	}
-
-	if string(result) != expected {
-		t.Errorf("Expected: %s, Got: %s", expected, string(result))
+	if _, err := codec.Encode("user_name.1"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, Got: %v", err)
 	}
 }
 
-func TestLookupRelationshipResponseUnmarshalJSON(t *testing.T) {
-	jsonStr := `{"code":404,"message":"Not Found","details":["Detail1","Detail2"],"entity_ids":["org_1224","abc_5678"]}`
-	expected := &LookupRelationshipResponse{
-		ErrorResponse: &ErrorResponse{Code: 404, Message: "Not Found", Details: []string{"Detail1", "Detail2"}},
-		EntityIDs:     []string{"org.1224", "abc.5678"},
-	}
-	var lookupResponse LookupRelationshipResponse
-	err := json.Unmarshal([]byte(jsonStr), &lookupResponse)
-
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
-
-	if lookupResponse.Code != expected.Code || lookupResponse.Message != expected.Message || len(lookupResponse.Details) != len(expected.Details) {
-		t.Errorf("Expected: %+v, Got: %+v", expected, lookupResponse)
-	}
-	for i, detail := range lookupResponse.Details {
-		if detail != expected.Details[i] {
-			t.Errorf("Expected: %s, Got: %s", expected.Details[i], detail)
-		}
-	}
-	if len(lookupResponse.EntityIDs) != len(expected.EntityIDs) {
-		t.Errorf("Expected entity IDs length: %d, Got: %d", len(expected.EntityIDs), len(lookupResponse.EntityIDs))
-	}
-	for i, id := range lookupResponse.EntityIDs {
-		if id != expected.EntityIDs[i] {
-			t.Errorf("Expected: %s, Got: %s", expected.EntityIDs[i], id)
-		}
-	}
-}
-
-func TestSubjectIDSetMarshalJSON(t *testing.T) {
-	subjectIDSet := &SubjectIDSet{Type: "type", Ids: []string{"org.1224", "abc.5678"}, Relation: "rel"}
-	expected := `{"type":"type","ids":["org_1224","abc_5678"],"relation":"rel"}`
-	result, err := json.Marshal(subjectIDSet)
-
-	if err != nil {
-		t.Errorf("Error marshalling JSON: %v", err)
-	}
-
-	if string(result) != expected {
-		t.Errorf("Expected: %s, Got: %s", expected, string(result))
-	}
-}
-
-func TestSubjectIDSetUnmarshalJSON(t *testing.T) {
-	jsonStr := `{"type":"type","ids":["org_1224","abc_5678"],"relation":"rel"}`
-	expected := &SubjectIDSet{Type: "type", Ids: []string{"org.1224", "abc.5678"}, Relation: "rel"}
-	var subjectIDSet SubjectIDSet
-	err := json.Unmarshal([]byte(jsonStr), &subjectIDSet)
-
-	if err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
-
-	if subjectIDSet.Type != expected.Type || len(subjectIDSet.Ids) != len(expected.Ids) || subjectIDSet.Relation != expected.Relation {
-		t.Errorf("Expected: %+v, Got: %+v", expected, subjectIDSet)
-	}
-	for i, id := range subjectIDSet.Ids {
-		if id != expected.Ids[i] {
-			t.Errorf("Expected: %s, Got: %s", expected.Ids[i], id)
+func TestEncoder(t *testing.T) {
+	encode := encoder(EscapeIDCodec{})
+	for _, id := range []string{"", WildcardID} {
+		if result, err := encode(id); err != nil || result != id {
+			t.Errorf("Expected %q to pass through, Got: %q (%v)", id, result, err)
 		}
 	}
-}
+	if _, err := encode(strings.Repeat("a", MaxIDLength+1)); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for a long id, Got: %v", err)
+	}
+	if _, err := encoder(lossyCodec{})("a.b"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for an id that does not round trip, Got: %v", err)
+	}
+	if _, err := encoder(identityCodec{})("a.b"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for an id outside the alphabet, Got: %v", err)
+	}
+}
+
+func TestTranscodeIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "entity and subject",
+			input:    `{"entity":{"type":"team","id":"org.1"},"subject":{"type":"user","id":"user_name.1","relation":""}}`,
+			expected: `{"entity":{"id":"org_1","type":"team"},"subject":{"id":"user-_name_1","relation":"","type":"user"}}`,
+		},
+		{
+			name:     "id sets",
+			input:    `{"filter":{"entity":{"type":"team","ids":["org.1","org.2"]},"subject":{"type":"user","ids":["a.b"],"relation":"member"}}}`,
+			expected: `{"filter":{"entity":{"ids":["org_1","org_2"],"type":"team"},"subject":{"ids":["a_b"],"relation":"member","type":"user"}}}`,
+		},
+		{
+			name:     "id lists",
+			input:    `{"entity_ids":["org.1"],"subject_ids":["user.1"],"continuous_token":"a.b"}`,
+			expected: `{"continuous_token":"a.b","entity_ids":["org_1"],"subject_ids":["user_1"]}`,
+		},
+		{
+			name:     "data is left alone",
+			input:    `{"context":{"data":{"entity":{"type":"ip","id":"1.2.3.4"}}},"depth":20}`,
+			expected: `{"context":{"data":{"entity":{"id":"1.2.3.4","type":"ip"}}},"depth":20}`,
+		},
+		{
+			name:     "not json",
+			input:    `404 page not found`,
+			expected: `404 page not found`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := transcodeIDs([]byte(tt.input), encoder(EscapeIDCodec{}))
+			if err != nil {
+				t.Fatalf("Error transcoding: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("Expected: %s, Got: %s", tt.expected, string(result))
+			}
+		})
+	}
+
+	decoded, err := transcodeIDs([]byte(`{"entity_ids":["user-_name_1"]}`), decoder(EscapeIDCodec{}))
+	if err != nil || string(decoded) != `{"entity_ids":["user_name.1"]}` {
+		t.Errorf("Expected the ids to be decoded, Got: %s (%v)", decoded, err)
+	}
+	if _, err := transcodeIDs([]byte(`{"entity_ids":["a.b_c"]}`), encoder(SeparatorIDCodec{})); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, Got: %v", err)
+	}
+}
+
+// lossyCodec drops dots, so IDs with dots do not round trip.
+type lossyCodec struct{}
+
+func (lossyCodec) Encode(id string) (string, error) { return strings.ReplaceAll(id, ".", ""), nil }
+func (lossyCodec) Decode(id string) (string, error) { return id, nil }
+
+// identityCodec leaves IDs as they are.
+type identityCodec struct{}
+
+func (identityCodec) Encode(id string) (string, error) { return id, nil }
+func (identityCodec) Decode(id string) (string, error) { return id, nil }
@@ -0,0 +1,114 @@
+package permify
+
+import (
+	"context"
+)
+
+// DefaultDepth limits the depth of the relationship graph search of checks,
+// lookups and expansions that do not set one.
+const DefaultDepth = 100
+
+// Consistency pins what a read is evaluated against. Empty fields are left
+// to the request's Metadata or the client's defaults.
+type Consistency struct {
+	SnapToken     string // Evaluate at least as fresh as this snapshot, e.g. the token of a write
+	SchemaVersion string // Evaluate against this schema version instead of the latest
+	Depth         int    // Depth limit of the graph search
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context whose reads use the non-empty fields of
+// consistency, overriding those set by earlier calls.
+func WithConsistency(ctx context.Context, consistency Consistency) context.Context {
+	current := consistencyFromContext(ctx)
+	if consistency.SnapToken != "" {
+		current.SnapToken = consistency.SnapToken
+	}
+	if consistency.SchemaVersion != "" {
+		current.SchemaVersion = consistency.SchemaVersion
+	}
+	if consistency.Depth > 0 {
+		current.Depth = consistency.Depth
+	}
+	return context.WithValue(ctx, consistencyKey{}, current)
+}
+
+// WithSnapToken returns a context whose reads see at least the given snapshot.
+func WithSnapToken(ctx context.Context, token string) context.Context {
+	return WithConsistency(ctx, Consistency{SnapToken: token})
+}
+
+// WithSchemaVersion returns a context whose reads use the given schema version.
+func WithSchemaVersion(ctx context.Context, version string) context.Context {
+	return WithConsistency(ctx, Consistency{SchemaVersion: version})
+}
+
+// WithDepth returns a context whose reads search the graph up to depth.
+func WithDepth(ctx context.Context, depth int) context.Context {
+	return WithConsistency(ctx, Consistency{Depth: depth})
+}
+
+func consistencyFromContext(ctx context.Context) Consistency {
+	consistency, _ := ctx.Value(consistencyKey{}).(Consistency)
+	return consistency
+}
+
+// ConsistencyReporter is implemented by clients that track the snapshots of
+// their writes.
+type ConsistencyReporter interface {
+	// LatestSnapToken returns the snap token of the most recent successful
+	// write or delete, empty if there was none.
+	LatestSnapToken() string
+}
+
+// LatestSnapToken implements ConsistencyReporter.
+func (c *client) LatestSnapToken() string {
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	return c.snapToken
+}
+
+// observeSnapToken records the snap token returned by a write. Writes may
+// complete out of order, so an older token never replaces a newer one.
+// Tokens CompareSnapTokens cannot order replace the recorded one.
+func (c *client) observeSnapToken(token string) {
+	if token == "" {
+		return
+	}
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	if c.snapToken != "" && CompareSnapTokens(c.snapToken, token) > 0 {
+		return
+	}
+	c.snapToken = token
+}
+
+// metadata resolves the metadata of a read. Fields set by the caller win over
+// the context's Consistency, which wins over the latest snap token when
+// ReadYourWrites is enabled and over DefaultDepth.
+func (c *client) metadata(ctx context.Context, metadata Metadata) Metadata {
+	consistency := consistencyFromContext(ctx)
+	if metadata.Snap == "" {
+		metadata.Snap = consistency.SnapToken
+	}
+	if metadata.Snap == "" && c.config.ReadYourWrites {
+		metadata.Snap = c.LatestSnapToken()
+	}
+	if metadata.Schema == "" {
+		metadata.Schema = consistency.SchemaVersion
+	}
+	if metadata.Depth <= 0 {
+		metadata.Depth = consistency.Depth
+	}
+	if metadata.Depth <= 0 {
+		metadata.Depth = DefaultDepth
+	}
+	return metadata
+}
+
+// snapshot resolves the metadata of a read of stored data, which only takes
+// a snap token.
+func (c *client) snapshot(ctx context.Context, metadata Metadata) Metadata {
+	return Metadata{Snap: c.metadata(ctx, metadata).Snap}
+}
@@ -0,0 +1,145 @@
+package permify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeSent decodes the JSON body of a request sent by the client into v.
+func decodeSent(t *testing.T, req *http.Request, v interface{}) {
+	body, err := req.GetBody()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, v))
+}
+
+// sentMetadata decodes the metadata of a request sent by the client.
+func sentMetadata(t *testing.T, req *http.Request) permify.Metadata {
+	var payload struct {
+		Metadata permify.Metadata `json:"metadata"`
+	}
+	decodeSent(t, req, &payload)
+	return payload.Metadata
+}
+
+func TestConsistency(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+
+	t.Run("Defaults", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: allowed})
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, permify.Metadata{Depth: permify.DefaultDepth}, sentMetadata(t, rt.requests[0]))
+	})
+
+	t.Run("Context Options", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: allowed})
+
+		ctx := permify.WithSnapToken(ctx, "snap1")
+		ctx = permify.WithSchemaVersion(ctx, "schema1")
+		ctx = permify.WithDepth(ctx, 5)
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, permify.Metadata{Snap: "snap1", Schema: "schema1", Depth: 5}, sentMetadata(t, rt.requests[0]))
+	})
+
+	t.Run("Request Metadata Wins And Is Not Modified", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"entity_ids": []}`})
+
+		request := &permify.LookupRelationshipRequest{
+			Metadata:   permify.Metadata{Snap: "mine"},
+			EntityType: "doc",
+			Permission: "read",
+			Subject:    subject,
+		}
+		_, err := client.LookupRelationship(permify.WithConsistency(ctx, permify.Consistency{SnapToken: "ctx", Depth: 3}), request)
+		assert.NoError(t, err)
+		assert.Equal(t, permify.Metadata{Snap: "mine", Depth: 3}, sentMetadata(t, rt.requests[0]))
+		assert.Equal(t, permify.Metadata{Snap: "mine"}, request.Metadata)
+	})
+
+	t.Run("Read Your Writes", func(t *testing.T) {
+		client, rt := newTestClient(readYourWrites,
+			mockStep{status: http.StatusOK, body: `{"snap_token": "write1"}`},
+			mockStep{status: http.StatusOK, body: allowed},
+			mockStep{status: http.StatusOK, body: `{"snap_token": "delete1"}`},
+			mockStep{status: http.StatusOK, body: `{"entity_ids": []}`},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		assert.Empty(t, client.(permify.ConsistencyReporter).LatestSnapToken())
+
+		_, err := client.AddRelationship(ctx, &permify.AddRelationshipRequest{
+			Relationships: []*permify.Relationship{{Entity: entity, Relation: "member", Subject: subject}},
+		})
+		assert.NoError(t, err)
+		_, err = client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "write1", sentMetadata(t, rt.requests[1]).Snap)
+
+		err = client.DeleteRelationship(ctx, &permify.DeleteRelationshipRequest{
+			Filter: permify.RelationshipFilter{
+				Entity:   permify.EntityIDSet{Type: "workspace", Ids: []string{"ws123"}},
+				Relation: "member",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "delete1", client.(permify.ConsistencyReporter).LatestSnapToken())
+		_, err = client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{EntityType: "doc", Permission: "read", Subject: subject})
+		assert.NoError(t, err)
+		assert.Equal(t, "delete1", sentMetadata(t, rt.requests[3]).Snap)
+
+		// an explicit snap token still wins
+		_, err = client.CheckPermission(permify.WithSnapToken(ctx, "older"), subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "older", sentMetadata(t, rt.requests[4]).Snap)
+	})
+
+	t.Run("Newest Write Wins", func(t *testing.T) {
+		older, newer := postgresSnapToken(41), postgresSnapToken(42)
+		// the newer write completes first
+		client, rt := newTestClient(readYourWrites,
+			mockStep{status: http.StatusOK, body: `{"snap_token": "` + newer + `"}`},
+			mockStep{status: http.StatusOK, body: `{"snap_token": "` + older + `"}`},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		write := &permify.AddRelationshipRequest{
+			Relationships: []*permify.Relationship{{Entity: entity, Relation: "member", Subject: subject}},
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := client.AddRelationship(ctx, write)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, newer, client.(permify.ConsistencyReporter).LatestSnapToken())
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, newer, sentMetadata(t, rt.requests[2]).Snap)
+	})
+
+	t.Run("Writes Are Not Carried Without Read Your Writes", func(t *testing.T) {
+		client, rt := newTestClient(nil,
+			mockStep{status: http.StatusOK, body: `{"snap_token": "write1"}`},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+
+		_, err := client.AddRelationship(ctx, &permify.AddRelationshipRequest{
+			Relationships: []*permify.Relationship{{Entity: entity, Relation: "member", Subject: subject}},
+		})
+		assert.NoError(t, err)
+		_, err = client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Empty(t, sentMetadata(t, rt.requests[1]).Snap)
+	})
+}
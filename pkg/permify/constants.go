@@ -4,6 +4,13 @@ const (
 	// Content type for JSON requests
 	ContentTypeHeader = "Content-Type"
 	ContentTypeJSON   = "application/json"
+
+	// Header and scheme used to send credentials
+	AuthorizationHeader = "Authorization"
+	BearerPrefix        = "Bearer "
+
+	// Header the server uses to ask clients to back off
+	RetryAfterHeader = "Retry-After"
 )
 
 const (
@@ -18,17 +25,99 @@ const (
 
 	// Base path for the check permissions API endpoint
 	PermissionCheckAPIPath = "/%s/tenants/%s/permissions/check"
+	// Base path for the bulk check permissions API endpoint
+	BulkCheckAPIPath = "/%s/tenants/%s/permissions/bulk-check"
+	// Base path for the subject permission API endpoint
+	SubjectPermissionAPIPath = "/%s/tenants/%s/permissions/subject-permission"
 	// Base path for the relationship ADD API endpoint
 	RelationshipAPIPath = "/%s/tenants/%s/relationships/write"
 	// Base path for the lookup FIND relationship API endpoint
 	LookupRelationshipAPIPath = "/%s/tenants/%s/permissions/lookup-entity"
+	// Base path for the lookup subject API endpoint
+	LookupSubjectAPIPath = "/%s/tenants/%s/permissions/lookup-subject"
 	// Base path for the LIST/find relationship API endpoint
 	FindRelationshipsAPIPath = "/%s/tenants/%s/permissions/expand"
 	// Base path for the DELETE relationship API endpoint
 	DeleteRelationshipAPIPath = "/%s/tenants/%s/relationships/delete"
+
+	// Base path for writing relationships and attributes together
+	DataWriteAPIPath = "/%s/tenants/%s/data/write"
+	// Base path for reading attributes
+	AttributeReadAPIPath = "/%s/tenants/%s/data/attributes/read"
+	// Base path for deleting relationships and attributes together
+	DataDeleteAPIPath = "/%s/tenants/%s/data/delete"
+	// Base path for reading the stored relationships
+	RelationshipReadAPIPath = "/%s/tenants/%s/data/relationships/read"
 )
 
 const (
 	// Value returned from the auth server when the permission is granted
 	CheckResponseAllowed = "CHECK_RESULT_ALLOWED"
 )
+
+// Operation names a client call. It is used to decide retry eligibility
+// and to group requests per call type.
+type Operation string
+
+const (
+	OperationCheck             Operation = "check"
+	OperationBulkCheck         Operation = "bulk_check"
+	OperationSubjectPermission Operation = "subject_permission"
+	OperationLookup            Operation = "lookup"
+	OperationLookupSubject     Operation = "lookup_subject"
+	OperationExpand            Operation = "expand"
+	OperationWrite             Operation = "write"
+	OperationDelete            Operation = "delete"
+	OperationWriteData         Operation = "write_data"
+	OperationReadAttributes    Operation = "read_attributes"
+	OperationReadRelationships Operation = "read_relationships"
+	OperationDeleteData        Operation = "delete_data"
+	OperationCreateTenant      Operation = "create_tenant"
+	OperationDeleteTenant      Operation = "delete_tenant"
+	OperationListTenants       Operation = "list_tenants"
+	OperationWriteSchema       Operation = "write_schema"
+)
+
+// Bucket names a rate limiter budget shared by a class of operations.
+type Bucket string
+
+const (
+	BucketGlobal Bucket = "global"
+	BucketCheck  Bucket = "check"
+	BucketLookup Bucket = "lookup"
+	BucketExpand Bucket = "expand"
+	BucketWrite  Bucket = "write"
+	BucketDelete Bucket = "delete"
+	BucketRead   Bucket = "read"  // reads of stored relationships and attributes
+	BucketAdmin  Bucket = "admin" // tenant and schema management
+)
+
+// Bucket returns the rate limiter budget the operation draws from.
+func (o Operation) Bucket() Bucket {
+	switch o {
+	case OperationCheck, OperationBulkCheck, OperationSubjectPermission:
+		return BucketCheck
+	case OperationLookup, OperationLookupSubject:
+		return BucketLookup
+	case OperationExpand:
+		return BucketExpand
+	case OperationReadAttributes, OperationReadRelationships:
+		return BucketRead
+	case OperationWrite, OperationWriteData:
+		return BucketWrite
+	case OperationDelete, OperationDeleteData:
+		return BucketDelete
+	}
+	return BucketAdmin
+}
+
+// IsIdempotent reports whether repeating the operation is free of side effects.
+func (o Operation) IsIdempotent() bool {
+	switch o {
+	case OperationCheck, OperationBulkCheck, OperationSubjectPermission,
+		OperationLookup, OperationLookupSubject, OperationExpand,
+		OperationReadAttributes, OperationReadRelationships, OperationListTenants:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package permify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextualData(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "org.alice"}
+	entity := &permify.Entity{Type: "doc", Id: "org.doc1"}
+
+	newContext := func() *permify.Context {
+		return &permify.Context{
+			Tuples: []*permify.Relationship{{
+				Entity:   &permify.Entity{Type: "doc", Id: "org.doc1"},
+				Relation: "viewer",
+				Subject:  &permify.Subject{Type: "link", Id: "share.abc"},
+			}},
+			Attributes: []*permify.Attribute{{
+				Entity:    &permify.Entity{Type: "doc", Id: "org.doc1"},
+				Attribute: "is_public",
+				Value:     permify.BooleanValue(true),
+			}},
+			Data: map[string]interface{}{"ip": "10.0.0.1"},
+		}
+	}
+	expected := `{
+		"tuples": [{"entity": {"type": "doc", "id": "org_doc1"}, "relation": "viewer", "subject": {"type": "link", "id": "share_abc"}}],
+		"attributes": [{"entity": {"type": "doc", "id": "org_doc1"}, "attribute": "is_public",
+			"value": {"@type": "type.googleapis.com/base.v1.BooleanValue", "data": true}}],
+		"data": {"ip": "10.0.0.1"}
+	}`
+	sentContext := func(t *testing.T, req *http.Request) string {
+		var payload struct {
+			Context json.RawMessage `json:"context"`
+		}
+		decodeSent(t, req, &payload)
+		return string(payload.Context)
+	}
+
+	t.Run("Check", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`})
+
+		_, err := client.Check(ctx, &permify.PermissionCheckRequest{
+			Entity:     entity,
+			Permission: "view",
+			Subject:    subject,
+			Context:    newContext(),
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, expected, sentContext(t, rt.requests[0]))
+	})
+
+	t.Run("Check Options", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`})
+
+		c := newContext()
+		_, err := client.Check(ctx, &permify.PermissionCheckRequest{Entity: entity, Permission: "view", Subject: subject},
+			permify.CheckContextualTuples(c.Tuples...),
+			permify.CheckContextAttributes(c.Attributes...),
+			permify.CheckContextData("ip", "10.0.0.1"))
+		require.NoError(t, err)
+		assert.JSONEq(t, expected, sentContext(t, rt.requests[0]))
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"entity_ids": []}`})
+
+		_, err := client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{
+			EntityType: "doc",
+			Permission: "view",
+			Subject:    subject,
+			Context:    newContext(),
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, expected, sentContext(t, rt.requests[0]))
+	})
+
+	t.Run("Expand", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"tree": {}}`})
+
+		_, err := client.FindRelationships(ctx, &permify.FindRelationshipsRequest{
+			Entity:     entity,
+			Permission: "view",
+			Context:    newContext(),
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, expected, sentContext(t, rt.requests[0]))
+	})
+
+	t.Run("Malformed Context", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{}`})
+
+		invalid := []*permify.Context{
+			{Tuples: []*permify.Relationship{nil}},
+			{Tuples: []*permify.Relationship{{Entity: entity, Subject: subject}}},
+			{Tuples: []*permify.Relationship{{Entity: &permify.Entity{Type: "doc"}, Relation: "viewer", Subject: subject}}},
+			{Attributes: []*permify.Attribute{nil}},
+			{Attributes: []*permify.Attribute{{Entity: entity, Value: permify.BooleanValue(true)}}},
+			{Attributes: []*permify.Attribute{{Entity: entity, Attribute: "age", Value: permify.AttributeValue{Type: permify.AttributeTypeInteger, Data: 3}}}},
+			{Attributes: []*permify.Attribute{{Entity: entity, Attribute: "age", Value: permify.AttributeValue{Type: "unknown", Data: 3}}}},
+		}
+		for _, c := range invalid {
+			_, err := client.Check(ctx, &permify.PermissionCheckRequest{Entity: entity, Permission: "view", Subject: subject, Context: c})
+			assert.Error(t, err)
+			_, err = client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{EntityType: "doc", Permission: "view", Subject: subject, Context: c})
+			assert.Error(t, err)
+			_, err = client.FindRelationships(ctx, &permify.FindRelationshipsRequest{Entity: entity, Permission: "view", Context: c})
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 0, rt.Calls())
+
+		result, err := client.CheckMany(ctx, []permify.CheckItem{{Entity: entity, Permission: "view", Subject: subject}},
+			&permify.CheckManyOptions{CheckOptions: []permify.CheckOption{permify.CheckContextualTuples(nil)}})
+		require.NoError(t, err)
+		assert.Error(t, result.Results[0].Err)
+		assert.Equal(t, 0, rt.Calls())
+	})
+}
+
+func TestAttributeValue(t *testing.T) {
+	values := []permify.AttributeValue{
+		permify.BooleanValue(true),
+		permify.StringValue("eu"),
+		permify.IntegerValue(42),
+		permify.DoubleValue(100.5),
+		permify.BooleanArrayValue(true, false),
+		permify.StringArrayValue("a", "b"),
+		permify.IntegerArrayValue(1, 2),
+		permify.DoubleArrayValue(1.5, 2.5),
+	}
+	for _, value := range values {
+		raw, err := json.Marshal(value)
+		require.NoError(t, err)
+
+		var decoded permify.AttributeValue
+		require.NoError(t, json.Unmarshal(raw, &decoded), string(raw))
+		assert.Equal(t, value, decoded)
+	}
+
+	// Permify leaves out zero values
+	var decoded permify.AttributeValue
+	require.NoError(t, json.Unmarshal([]byte(`{"@type": "type.googleapis.com/base.v1.IntegerValue"}`), &decoded))
+	assert.Equal(t, permify.IntegerValue(0), decoded)
+
+	for _, raw := range []string{
+		`{"@type": "type.googleapis.com/base.v1.Unknown", "data": 1}`,
+		`{"@type": "type.googleapis.com/base.v1.IntegerValue", "data": "one"}`,
+		`{"@type": "type.googleapis.com/base.v1.BooleanArrayValue", "data": true}`,
+	} {
+		err := json.Unmarshal([]byte(raw), &decoded)
+		assert.ErrorIs(t, err, permify.ErrInvalidAttributeValue, raw)
+	}
+}
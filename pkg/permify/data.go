@@ -0,0 +1,137 @@
+package permify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WriteData writes relationships and attributes in one request. On success
+// it returns the snapshot of the write, represented by the RelationshipSnap
+// structure. If the write fails, an error is returned.
+func (c *client) WriteData(ctx context.Context, request *WriteDataRequest) (*RelationshipSnap, error) {
+	if err := c.validateWriteDataRequest(request); err != nil {
+		return nil, err
+	}
+
+	url := c.constructURL(DataWriteAPIPath)
+	body, err := c.sendRequest(ctx, OperationWriteData, http.MethodPost, url, request)
+	if err != nil {
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response RelationshipSnap
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, ErrBodyDecodeFailure
+	}
+
+	if response.ErrorResponse != nil || response.SnapToken == "" {
+		return nil, responseError(OperationWriteData, url, response.ErrorResponse)
+	}
+	c.observeSnapToken(response.SnapToken)
+
+	return &response, nil
+}
+
+// ReadAttributes returns a page of the attributes matching the filter. The
+// response's ContinuousToken is set when there are more pages.
+func (c *client) ReadAttributes(ctx context.Context, request *ReadAttributesRequest) (*ReadAttributesResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if request.Filter.Entity.Type == "" {
+		return nil, fmt.Errorf("invalid entity in filter")
+	}
+	resolved := *request
+	resolved.Metadata = c.snapshot(ctx, request.Metadata)
+
+	url := c.constructURL(AttributeReadAPIPath)
+	body, err := c.sendRequest(ctx, OperationReadAttributes, http.MethodPost, url, &resolved)
+	if err != nil {
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response ReadAttributesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse read attributes response: %w", err)
+	}
+
+	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
+		return nil, responseError(OperationReadAttributes, url, response.ErrorResponse)
+	}
+
+	return &response, nil
+}
+
+// DeleteData deletes the relationships and attributes matching the filters
+// in one request and returns the snapshot of the deletion.
+func (c *client) DeleteData(ctx context.Context, request *DeleteDataRequest) (*RelationshipSnap, error) {
+	if err := c.validateDeleteDataRequest(request); err != nil {
+		return nil, err
+	}
+
+	url := c.constructURL(DataDeleteAPIPath)
+	body, err := c.sendRequest(ctx, OperationDeleteData, http.MethodPost, url, request)
+	if err != nil {
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response RelationshipSnap
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, ErrBodyDecodeFailure
+	}
+
+	if response.ErrorResponse != nil || response.SnapToken == "" {
+		return nil, responseError(OperationDeleteData, url, response.ErrorResponse)
+	}
+	c.observeSnapToken(response.SnapToken)
+
+	return &response, nil
+}
+
+// validateWriteDataRequest checks the validity of the WriteDataRequest
+func (c *client) validateWriteDataRequest(request *WriteDataRequest) error {
+	if request == nil {
+		return fmt.Errorf("request is nil")
+	}
+	if len(request.Relationships) == 0 && len(request.Attributes) == 0 {
+		return fmt.Errorf("request contains no relationships or attributes")
+	}
+	for i, r := range request.Relationships {
+		if r == nil {
+			return fmt.Errorf("relationship %d is nil", i)
+		}
+		if err := validate(r); err != nil {
+			return fmt.Errorf("relationship %d validation failed: %w", i, err)
+		}
+	}
+	for i, a := range request.Attributes {
+		if err := validateAttribute(a); err != nil {
+			return fmt.Errorf("attribute %d validation failed: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateDeleteDataRequest checks the filters of a DeleteDataRequest are
+// narrow enough, as with DeleteRelationship they must name the entities.
+func (c *client) validateDeleteDataRequest(request *DeleteDataRequest) error {
+	if request == nil {
+		return fmt.Errorf("request is nil")
+	}
+	if request.TupleFilter == nil && request.AttributeFilter == nil {
+		return fmt.Errorf("request contains no filter")
+	}
+	if request.TupleFilter != nil {
+		if err := c.validateDeleteFilter(&DeleteRelationshipRequest{Filter: *request.TupleFilter}); err != nil {
+			return err
+		}
+	}
+	if filter := request.AttributeFilter; filter != nil {
+		if filter.Entity.Type == "" || len(filter.Entity.Ids) == 0 {
+			return fmt.Errorf("invalid entity in attribute filter")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestData(t *testing.T) {
+	ctx := context.Background()
+	document := &permify.Entity{Type: "document", Id: "org.doc1"}
+
+	t.Run("WriteData", func(t *testing.T) {
+		client, rt := newTestClient(readYourWrites, mockStep{status: http.StatusOK, body: `{"snap_token": "snap1"}`})
+
+		snap, err := client.(permify.DataClient).WriteData(ctx, &permify.WriteDataRequest{
+			Relationships: []*permify.Relationship{{
+				Entity:   &permify.Entity{Type: "document", Id: "org.doc1"},
+				Relation: "owner",
+				Subject:  &permify.Subject{Type: "user", Id: "org.alice"},
+			}},
+			Attributes: []*permify.Attribute{
+				{Entity: &permify.Entity{Type: "document", Id: "org.doc1"}, Attribute: "is_public", Value: permify.BooleanValue(true)},
+				{Entity: &permify.Entity{Type: "account", Id: "acc1"}, Attribute: "balance", Value: permify.DoubleValue(100.5)},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "snap1", snap.SnapToken)
+		assert.Equal(t, "/v1/tenants/t1/data/write", rt.requests[0].URL.Path)
+
+		var sent map[string]interface{}
+		decodeSent(t, rt.requests[0], &sent)
+		assert.Equal(t, "org_doc1", sent["tuples"].([]interface{})[0].(map[string]interface{})["entity"].(map[string]interface{})["id"])
+		assert.Equal(t, "org_alice", sent["tuples"].([]interface{})[0].(map[string]interface{})["subject"].(map[string]interface{})["id"])
+		attribute := sent["attributes"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "org_doc1", attribute["entity"].(map[string]interface{})["id"])
+		assert.Equal(t, map[string]interface{}{"@type": string(permify.AttributeTypeBoolean), "data": true}, attribute["value"])
+
+		assert.Equal(t, "snap1", client.(permify.ConsistencyReporter).LatestSnapToken())
+	})
+
+	t.Run("WriteData Validation", func(t *testing.T) {
+		client, rt := newTestClient(readYourWrites, mockStep{status: http.StatusOK, body: `{"snap_token": "snap1"}`})
+
+		invalid := []*permify.WriteDataRequest{
+			nil,
+			{},
+			{Relationships: []*permify.Relationship{nil}},
+			{Relationships: []*permify.Relationship{{Entity: document, Subject: &permify.Subject{Type: "user", Id: "alice"}}}},
+			{Attributes: []*permify.Attribute{nil}},
+			{Attributes: []*permify.Attribute{{Entity: document, Attribute: "is_public"}}},
+			{Attributes: []*permify.Attribute{{Entity: document, Attribute: "age", Value: permify.AttributeValue{Type: permify.AttributeTypeInteger, Data: 3}}}},
+		}
+		for _, request := range invalid {
+			_, err := client.(permify.DataClient).WriteData(ctx, request)
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 0, rt.Calls())
+	})
+
+	t.Run("ReadAttributes", func(t *testing.T) {
+		client, rt := newTestClient(readYourWrites, mockStep{status: http.StatusOK, body: `{
+			"attributes": [
+				{"entity": {"type": "document", "id": "org_doc1"}, "attribute": "is_public",
+					"value": {"@type": "type.googleapis.com/base.v1.BooleanValue", "data": true}},
+				{"entity": {"type": "document", "id": "org_doc1"}, "attribute": "tags",
+					"value": {"@type": "type.googleapis.com/base.v1.StringArrayValue", "data": ["a", "b"]}}
+			],
+			"continuous_token": "next"
+		}`})
+
+		response, err := client.(permify.DataClient).ReadAttributes(permify.WithSnapToken(ctx, "snap1"), &permify.ReadAttributesRequest{
+			Filter:   permify.AttributeFilter{Entity: permify.EntityIDSet{Type: "document", Ids: []string{"org.doc1"}}},
+			PageSize: 10,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "/v1/tenants/t1/data/attributes/read", rt.requests[0].URL.Path)
+		assert.Equal(t, permify.Metadata{Snap: "snap1"}, sentMetadata(t, rt.requests[0]))
+
+		var sent struct {
+			Filter struct {
+				Entity struct {
+					Ids []string `json:"ids"`
+				} `json:"entity"`
+			} `json:"filter"`
+		}
+		decodeSent(t, rt.requests[0], &sent)
+		assert.Equal(t, []string{"org_doc1"}, sent.Filter.Entity.Ids)
+
+		require.Len(t, response.Attributes, 2)
+		assert.Equal(t, document, response.Attributes[0].Entity)
+		assert.Equal(t, permify.BooleanValue(true), response.Attributes[0].Value)
+		assert.Equal(t, permify.StringArrayValue("a", "b"), response.Attributes[1].Value)
+		assert.Equal(t, "next", response.ContinuousToken)
+
+		_, err = client.(permify.DataClient).ReadAttributes(ctx, &permify.ReadAttributesRequest{})
+		assert.Error(t, err)
+		assert.Equal(t, 1, rt.Calls())
+	})
+
+	t.Run("DeleteData", func(t *testing.T) {
+		client, rt := newTestClient(readYourWrites, mockStep{status: http.StatusOK, body: `{"snap_token": "snap2"}`})
+
+		for _, request := range []*permify.DeleteDataRequest{
+			nil,
+			{},
+			{AttributeFilter: &permify.AttributeFilter{Entity: permify.EntityIDSet{Type: "document"}}},
+			{TupleFilter: &permify.RelationshipFilter{Entity: permify.EntityIDSet{Type: "document", Ids: []string{"doc1"}}}},
+		} {
+			_, err := client.(permify.DataClient).DeleteData(ctx, request)
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 0, rt.Calls())
+
+		snap, err := client.(permify.DataClient).DeleteData(ctx, &permify.DeleteDataRequest{
+			AttributeFilter: &permify.AttributeFilter{
+				Entity:     permify.EntityIDSet{Type: "document", Ids: []string{"org.doc1"}},
+				Attributes: []string{"is_public"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "snap2", snap.SnapToken)
+		assert.Equal(t, "/v1/tenants/t1/data/delete", rt.requests[0].URL.Path)
+		assert.Equal(t, "snap2", client.(permify.ConsistencyReporter).LatestSnapToken())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		client, _ := newTestClient(readYourWrites, mockStep{status: http.StatusOK, body: `{"code": 3, "message": "invalid attribute"}`})
+
+		_, err := client.(permify.DataClient).WriteData(ctx, &permify.WriteDataRequest{
+			Attributes: []*permify.Attribute{{Entity: document, Attribute: "is_public", Value: permify.BooleanValue(true)}},
+		})
+		assert.ErrorIs(t, err, permify.ErrUnableToWriteData)
+	})
+}
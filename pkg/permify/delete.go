@@ -8,26 +8,34 @@ import (
 )
 
 func (c *client) DeleteRelationship(ctx context.Context, filter *DeleteRelationshipRequest) error {
+	_, err := c.deleteRelationship(ctx, filter)
+	return err
+}
+
+// deleteRelationship deletes the relationships matching filter and returns
+// the snapshot of the deletion.
+func (c *client) deleteRelationship(ctx context.Context, filter *DeleteRelationshipRequest) (*RelationshipSnap, error) {
 	if err := c.validateDeleteFilter(filter); err != nil {
-		return err
+		return nil, err
 	}
 
 	url := c.constructURL(DeleteRelationshipAPIPath)
-	body, err := c.sendRequest(ctx, http.MethodPost, url, filter)
+	body, err := c.sendRequest(ctx, OperationDelete, http.MethodPost, url, filter)
 	if err != nil {
-		return fmt.Errorf("permify request failed: %w", err)
+		return nil, fmt.Errorf("permify request failed: %w", err)
 	}
 
 	var response RelationshipSnap
 	if err := json.Unmarshal(body, &response); err != nil {
-		return ErrBodyDecodeFailure
+		return nil, ErrBodyDecodeFailure
 	}
 
 	if response.ErrorResponse != nil || response.SnapToken == "" {
-		return ErrUnableToDeleteRelationship
+		return nil, responseError(OperationDelete, url, response.ErrorResponse)
 	}
+	c.observeSnapToken(response.SnapToken)
 
-	return nil
+	return &response, nil
 }
 
 func (c *client) validateDeleteFilter(request *DeleteRelationshipRequest) error {
@@ -0,0 +1,123 @@
+package permify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodyLength caps how much of a non-JSON error body ends up in an APIError.
+const maxErrorBodyLength = 256
+
+// operationErrors maps each operation to the sentinel error its failures wrap,
+// so errors.Is keeps matching the sentinels callers already check for.
+var operationErrors = map[Operation]error{
+	OperationCheck:             ErrUnableToCheckRelationship,
+	OperationBulkCheck:         ErrUnableToCheckRelationship,
+	OperationSubjectPermission: ErrUnableToListPermissions,
+	OperationLookup:            ErrUnableToLookupRelationship,
+	OperationLookupSubject:     ErrUnableToLookupSubject,
+	OperationExpand:            ErrUnableToFindRelationships,
+	OperationWrite:             ErrUnableToCreateRelationship,
+	OperationDelete:            ErrUnableToDeleteRelationship,
+	OperationWriteData:         ErrUnableToWriteData,
+	OperationReadAttributes:    ErrUnableToReadAttributes,
+	OperationReadRelationships: ErrUnableToReadRelationships,
+	OperationDeleteData:        ErrUnableToDeleteData,
+	OperationCreateTenant:      ErrUnableToCreateTenant,
+	OperationDeleteTenant:      ErrUnableToDeleteTenant,
+	OperationListTenants:       ErrUnableToListTenant,
+	OperationWriteSchema:       ErrUnableToWriteSchema,
+}
+
+// APIError describes a call the Permify API rejected. It wraps the sentinel
+// error of the operation, so both errors.Is(err, ErrUnableToCheckRelationship)
+// and errors.As(err, &apiErr) work on the same error value.
+type APIError struct {
+	StatusCode int       // HTTP status code of the response
+	Code       int       // gRPC style code reported by Permify, zero if unknown
+	Message    string    // Message reported by Permify, or the raw body if it was not JSON
+	Details    []string  // Additional details reported by Permify
+	URL        string    // URL of the failed request
+	Operation  Operation // Operation that failed
+	Err        error     // Sentinel error for the operation
+}
+
+// newAPIError builds an APIError from a server response. The body is decoded
+// as an ErrorResponse when possible and kept as the message otherwise.
+func newAPIError(op Operation, url string, status int, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: status,
+		URL:        url,
+		Operation:  op,
+		Err:        operationErrors[op],
+	}
+
+	var response ErrorResponse
+	if err := json.Unmarshal(body, &response); err == nil && (response.Code != 0 || response.Message != "") {
+		apiErr.Code = response.Code
+		apiErr.Message = response.Message
+		apiErr.Details = response.Details
+		return apiErr
+	}
+
+	message := strings.TrimSpace(string(body))
+	if len(message) > maxErrorBodyLength {
+		message = message[:maxErrorBodyLength] + "..."
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	apiErr.Message = message
+	return apiErr
+}
+
+// responseError builds an APIError for an error reported in the body of a
+// successful HTTP response.
+func responseError(op Operation, url string, response *ErrorResponse) *APIError {
+	apiErr := &APIError{
+		StatusCode: http.StatusOK,
+		URL:        url,
+		Operation:  op,
+		Err:        operationErrors[op],
+	}
+	if response == nil {
+		apiErr.Message = "unexpected response"
+		return apiErr
+	}
+	apiErr.Code = response.Code
+	apiErr.Message = response.Message
+	apiErr.Details = response.Details
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	if e.Err != nil {
+		b.WriteString(e.Err.Error())
+	} else {
+		fmt.Fprintf(&b, "permify %s failed", e.Operation)
+	}
+	fmt.Fprintf(&b, ": http %d", e.StatusCode)
+	if e.Code != 0 {
+		fmt.Fprintf(&b, ", code %d", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if len(e.Details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(e.Details, "; "))
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is lets errors.Is(err, ErrServerThrottled) match responses with status 429,
+// keeping server side throttling apart from the client's own rate limiter.
+func (e *APIError) Is(target error) bool {
+	return target == ErrServerThrottled && e.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,82 @@
+package permify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+
+	t.Run("Permify Error Response", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient(`{"code":3,"message":"entity definition not found","details":["workspace"]}`, http.StatusBadRequest)
+		client := permify.NewClient(config)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		var apiErr *permify.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.True(t, errors.Is(err, permify.ErrUnableToCheckRelationship))
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, 3, apiErr.Code)
+		assert.Equal(t, "entity definition not found", apiErr.Message)
+		assert.Equal(t, []string{"workspace"}, apiErr.Details)
+		assert.Equal(t, permify.OperationCheck, apiErr.Operation)
+		assert.Contains(t, apiErr.URL, "/permissions/check")
+		assert.Equal(t, "failed to check relationship: http 400, code 3: entity definition not found (workspace)", apiErr.Error())
+	})
+
+	t.Run("Non JSON Error Body", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient("<html><body>502 Bad Gateway</body></html>", http.StatusBadGateway)
+		client := permify.NewClient(config)
+
+		_, err := client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{
+			EntityType: "doc",
+			Permission: "read",
+			Subject:    subject,
+		})
+		var apiErr *permify.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.False(t, errors.Is(err, permify.ErrBodyDecodeFailure))
+		assert.True(t, errors.Is(err, permify.ErrUnableToLookupRelationship))
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Equal(t, "<html><body>502 Bad Gateway</body></html>", apiErr.Message)
+	})
+
+	t.Run("Empty Error Body", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient("", http.StatusServiceUnavailable)
+		client := permify.NewClient(config).(permify.SchemaManagerClient)
+
+		_, err := client.SaveModelSchema(ctx, &permify.SaveSchemaRequest{Schema: "entity user {}"})
+		var apiErr *permify.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.True(t, errors.Is(err, permify.ErrUnableToWriteSchema))
+		assert.Equal(t, http.StatusText(http.StatusServiceUnavailable), apiErr.Message)
+	})
+
+	t.Run("Error In Successful Response", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient(`{"code":13,"message":"internal"}`, http.StatusOK)
+		client := permify.NewClient(config)
+
+		err := client.DeleteRelationship(ctx, &permify.DeleteRelationshipRequest{
+			Filter: permify.RelationshipFilter{
+				Entity:   permify.EntityIDSet{Type: "doc", Ids: []string{"doc1"}},
+				Relation: "owner",
+			},
+		})
+		var apiErr *permify.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.True(t, errors.Is(err, permify.ErrUnableToDeleteRelationship))
+		assert.Equal(t, 13, apiErr.Code)
+	})
+}
@@ -0,0 +1,197 @@
+package permify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ExpandOperation is the set operation an expand node applies to its children.
+type ExpandOperation string
+
+const (
+	ExpandUnion        ExpandOperation = "OPERATION_UNION"        // or, subjects of any child
+	ExpandIntersection ExpandOperation = "OPERATION_INTERSECTION" // and, subjects of every child
+	ExpandExclusion    ExpandOperation = "OPERATION_EXCLUSION"    // not, subjects of the first child but none of the others
+)
+
+// ExpandTree is a node of the expansion of a permission, as returned by
+// Permify's expand endpoint. Operation nodes set Expand, e.g. the union of
+// "owner or editor", and leaves set Leaf with the subjects of a relation.
+// Computed usersets and tuple to userset rewrites show up as nested nodes
+// for the entity and permission they point at.
+type ExpandTree struct {
+	Entity     *Entity     `json:"entity,omitempty"`
+	Permission string      `json:"permission,omitempty"` // Permission or relation the node expands
+	Expand     *ExpandNode `json:"expand,omitempty"`
+	Leaf       *ExpandLeaf `json:"leaf,omitempty"`
+}
+
+// ExpandNode combines the subjects of its children with Operation.
+type ExpandNode struct {
+	Operation ExpandOperation `json:"operation"`
+	Children  []*ExpandTree   `json:"children"`
+}
+
+// ExpandLeaf holds the subjects stored for a relation.
+type ExpandLeaf struct {
+	Subjects []*ExpandSubject
+}
+
+// ExpandSubject is a subject of a leaf. Relation is set for subject sets
+// that were not expanded further, e.g. team:1#member.
+type ExpandSubject struct {
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+	Relation string `json:"relation"`
+}
+
+func (l *ExpandLeaf) MarshalJSON() ([]byte, error) {
+	var aux struct {
+		Subjects struct {
+			Subjects []*ExpandSubject `json:"subjects"`
+		} `json:"subjects"`
+	}
+	aux.Subjects.Subjects = l.Subjects
+	return json.Marshal(&aux)
+}
+
+func (l *ExpandLeaf) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Subjects struct {
+			Subjects []*ExpandSubject `json:"subjects"`
+		} `json:"subjects"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	l.Subjects = aux.Subjects.Subjects
+	return nil
+}
+
+// Expand returns the full expansion of the permission on the entity.
+func (c *client) Expand(ctx context.Context, request *FindRelationshipsRequest) (*ExpandTree, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if request.Entity == nil || request.Entity.Type == "" || request.Entity.Id == "" {
+		return nil, fmt.Errorf("invalid entity")
+	}
+	if err := validateContext(request.Context); err != nil {
+		return nil, err
+	}
+	return c.expand(ctx, request)
+}
+
+// expand asks the server for the expansion of a permission.
+func (c *client) expand(ctx context.Context, request *FindRelationshipsRequest) (*ExpandTree, error) {
+	resolved := *request
+	resolved.Metadata = c.metadata(ctx, request.Metadata)
+
+	url := c.constructURL(FindRelationshipsAPIPath)
+
+	body, err := c.sendRequest(ctx, OperationExpand, http.MethodPost, url, &resolved)
+	if err != nil {
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response findRelationshipsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse expand response: %w", err)
+	}
+
+	if response.ErrorResponse != nil {
+		return nil, responseError(OperationExpand, url, response.ErrorResponse)
+	}
+
+	return &response.Tree, nil
+}
+
+// Walk calls fn for the node and its descendants, parents before children.
+// depth is 0 for the node Walk is called on. When fn returns false the
+// children of that node are skipped.
+func (t *ExpandTree) Walk(fn func(node *ExpandTree, depth int) bool) {
+	t.walk(fn, 0)
+}
+
+func (t *ExpandTree) walk(fn func(node *ExpandTree, depth int) bool, depth int) {
+	if t == nil || !fn(t, depth) || t.Expand == nil {
+		return
+	}
+	for _, child := range t.Expand.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Subjects returns the distinct subjects of all leaves of the tree, in the
+// order they first appear. Operations are ignored, so subjects excluded by
+// an exclusion or missing from a branch of an intersection are included,
+// use Reachable for the subjects the tree grants.
+func (t *ExpandTree) Subjects() []ExpandSubject {
+	var subjects []ExpandSubject
+	seen := make(map[ExpandSubject]bool)
+	t.Walk(func(node *ExpandTree, _ int) bool {
+		if node.Leaf == nil {
+			return true
+		}
+		for _, s := range node.Leaf.Subjects {
+			if s != nil && !seen[*s] {
+				seen[*s] = true
+				subjects = append(subjects, *s)
+			}
+		}
+		return true
+	})
+	return subjects
+}
+
+// Reachable returns the leaf subjects the tree grants, applying the set
+// operation of each node, in the order they first appear. Call it on a
+// child to get the subjects reachable through that branch.
+func (t *ExpandTree) Reachable() []ExpandSubject {
+	reachable := t.reachable()
+	var subjects []ExpandSubject
+	for _, s := range t.Subjects() {
+		if reachable[s] {
+			subjects = append(subjects, s)
+		}
+	}
+	return subjects
+}
+
+func (t *ExpandTree) reachable() map[ExpandSubject]bool {
+	set := make(map[ExpandSubject]bool)
+	switch {
+	case t == nil:
+	case t.Leaf != nil:
+		for _, s := range t.Leaf.Subjects {
+			if s != nil {
+				set[*s] = true
+			}
+		}
+	case t.Expand != nil && len(t.Expand.Children) > 0:
+		children := t.Expand.Children
+		set = children[0].reachable()
+		for _, child := range children[1:] {
+			other := child.reachable()
+			switch t.Expand.Operation {
+			case ExpandIntersection:
+				for s := range set {
+					if !other[s] {
+						delete(set, s)
+					}
+				}
+			case ExpandExclusion:
+				for s := range other {
+					delete(set, s)
+				}
+			default:
+				for s := range other {
+					set[s] = true
+				}
+			}
+		}
+	}
+	return set
+}
@@ -0,0 +1,95 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expandResponse expands edit = (owner or (org.admin and not banned)) or parent.member
+const expandResponse = `{"tree": {
+	"entity": {"type": "repo", "id": "org.1"},
+	"permission": "edit",
+	"expand": {"operation": "OPERATION_UNION", "children": [
+		{"entity": {"type": "repo", "id": "org.1"}, "permission": "owner",
+			"leaf": {"subjects": {"subjects": [{"type": "user", "id": "org.alice"}]}}},
+		{"entity": {"type": "repo", "id": "org.1"}, "permission": "edit",
+			"expand": {"operation": "OPERATION_EXCLUSION", "children": [
+				{"entity": {"type": "org", "id": "acme"}, "permission": "admin",
+					"leaf": {"subjects": {"subjects": [{"type": "user", "id": "bob"}, {"type": "user", "id": "carol"}]}}},
+				{"entity": {"type": "repo", "id": "org.1"}, "permission": "banned",
+					"leaf": {"subjects": {"subjects": [{"type": "user", "id": "carol"}]}}}
+			]}},
+		{"entity": {"type": "repo", "id": "org.1"}, "permission": "parent",
+			"leaf": {"subjects": {"subjects": [{"type": "team", "id": "eng", "relation": "member"}, {"type": "user", "id": "bob"}]}}}
+	]}
+}}`
+
+func TestExpand(t *testing.T) {
+	ctx := context.Background()
+	user := func(id string) permify.ExpandSubject {
+		return permify.ExpandSubject{Type: "user", Id: id}
+	}
+	team := permify.ExpandSubject{Type: "team", Id: "eng", Relation: "member"}
+
+	httpClient, rt := newSequenceClient(mockStep{status: http.StatusOK, body: expandResponse})
+	config := permify.NewDefaultConfig()
+	config.Client = httpClient
+	client := permify.NewClient(config)
+
+	tree, err := client.Expand(ctx, &permify.FindRelationshipsRequest{
+		Entity:     &permify.Entity{Type: "repo", Id: "org.1"},
+		Permission: "edit",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/tenants/t1/permissions/expand", rt.requests[0].URL.Path)
+
+	t.Run("Model", func(t *testing.T) {
+		assert.Equal(t, &permify.Entity{Type: "repo", Id: "org.1"}, tree.Entity)
+		require.NotNil(t, tree.Expand)
+		assert.Equal(t, permify.ExpandUnion, tree.Expand.Operation)
+		require.Len(t, tree.Expand.Children, 3)
+		assert.Equal(t, permify.ExpandExclusion, tree.Expand.Children[1].Expand.Operation)
+		assert.Equal(t, []*permify.ExpandSubject{{Type: "user", Id: "org.alice"}}, tree.Expand.Children[0].Leaf.Subjects)
+	})
+
+	t.Run("Subjects", func(t *testing.T) {
+		assert.Equal(t, []permify.ExpandSubject{user("org.alice"), user("bob"), user("carol"), team}, tree.Subjects())
+	})
+
+	t.Run("Reachable", func(t *testing.T) {
+		assert.Equal(t, []permify.ExpandSubject{user("org.alice"), user("bob"), team}, tree.Reachable())
+		assert.Equal(t, []permify.ExpandSubject{user("bob")}, tree.Expand.Children[1].Reachable())
+
+		intersection := &permify.ExpandTree{Expand: &permify.ExpandNode{
+			Operation: permify.ExpandIntersection,
+			Children:  tree.Expand.Children[1].Expand.Children,
+		}}
+		assert.Equal(t, []permify.ExpandSubject{user("carol")}, intersection.Reachable())
+		assert.Empty(t, (&permify.ExpandTree{}).Reachable())
+	})
+
+	t.Run("Walk", func(t *testing.T) {
+		var visited []string
+		var depths []int
+		tree.Walk(func(node *permify.ExpandTree, depth int) bool {
+			visited = append(visited, node.Permission)
+			depths = append(depths, depth)
+			return node.Permission != "edit" || depth == 0
+		})
+		assert.Equal(t, []string{"edit", "owner", "edit", "parent"}, visited)
+		assert.Equal(t, []int{0, 1, 1, 1}, depths)
+	})
+
+	t.Run("Invalid Request", func(t *testing.T) {
+		for _, req := range []*permify.FindRelationshipsRequest{nil, {Permission: "edit"}, {Entity: &permify.Entity{Type: "repo"}, Permission: "edit"}} {
+			_, err := client.Expand(ctx, req)
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 1, rt.Calls())
+	})
+}
@@ -0,0 +1,281 @@
+package permify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ExplainClient is implemented by clients that can tell why a permission was
+// granted or denied.
+type ExplainClient interface {
+	// Explain checks the permission and derives the decision from the
+	// expansion of the permission, quoting the rules of the schema.
+	Explain(ctx context.Context, subject *Subject, entity *Entity, permission string) (*Explanation, error)
+}
+
+var _ ExplainClient = (*client)(nil)
+
+// Explanation is the derivation of a permission check. Entities, subjects and
+// tuples are written the way Permify does, e.g. team:7#org@organization:3.
+type Explanation struct {
+	Subject    string       `json:"subject"`
+	Entity     string       `json:"entity"`
+	Permission string       `json:"permission"`
+	Allowed    bool         `json:"allowed"` // Decision of the check
+	Derivation *ExplainStep `json:"derivation"`
+	// Note is set when the expansion does not account for the decision,
+	// e.g. because it depends on attributes.
+	Note string `json:"note,omitempty"`
+}
+
+// ExplainStep is a node of a derivation. Leaves name the tuple that grants
+// the subject the relation, or the one that is missing.
+type ExplainStep struct {
+	Entity     string          `json:"entity"`
+	Permission string          `json:"permission"`          // Permission or relation evaluated
+	Rule       string          `json:"rule,omitempty"`      // Rule of the permission in the schema
+	Operation  ExpandOperation `json:"operation,omitempty"` // Set operation of the children
+	Via        string          `json:"via,omitempty"`       // Tuple that leads from the parent to this entity
+	Matched    bool            `json:"matched"`             // Whether the subject holds the permission through this step
+	Tuple      string          `json:"tuple,omitempty"`     // Tuple that grants a matched leaf
+	Missing    string          `json:"missing,omitempty"`   // Tuple that would grant an unmatched leaf
+	Children   []*ExplainStep  `json:"children,omitempty"`  // Branches that were evaluated
+}
+
+// Explain checks the permission and derives the decision from the expansion
+// of the permission. Rules are quoted from Config.Schema, or from the schema
+// last saved through the client.
+func (c *client) Explain(ctx context.Context, subject *Subject, entity *Entity, permission string) (*Explanation, error) {
+	if err := c.validatePermissionCheckInput(subject, entity, permission); err != nil {
+		return nil, err
+	}
+
+	decision, err := c.Check(ctx, &PermissionCheckRequest{Entity: entity, Permission: permission, Subject: subject})
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Expand(ctx, &FindRelationshipsRequest{
+		Metadata:   Metadata{Snap: decision.SnapToken, Schema: decision.SchemaVersion},
+		Entity:     entity,
+		Permission: permission,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e := explainer{
+		subject: ExpandSubject{Type: subject.Type, Id: subject.Id},
+		rules:   parseSchemaRules(c.explainSchema()),
+	}
+	explanation := &Explanation{
+		Subject:    formatSubject(e.subject),
+		Entity:     formatEntity(*entity),
+		Permission: permission,
+		Allowed:    decision.Allowed,
+		Derivation: e.step(tree, nil),
+	}
+	if explanation.Derivation.Matched != decision.Allowed {
+		explanation.Note = "the expansion does not account for the decision, it may depend on attributes or rules the expansion leaves out"
+	}
+	return explanation, nil
+}
+
+// explainSchema returns the schema rules are quoted from.
+func (c *client) explainSchema() string {
+	if c.config.Schema != "" {
+		return c.config.Schema
+	}
+	c.schemaMu.Lock()
+	defer c.schemaMu.Unlock()
+	return c.schema
+}
+
+type explainer struct {
+	subject ExpandSubject
+	rules   map[string]string // rule per entity type#permission
+}
+
+// step derives whether the subject is granted node.
+func (e explainer) step(node, parent *ExpandTree) *ExplainStep {
+	step := &ExplainStep{Permission: node.Permission}
+	if node.Entity != nil {
+		step.Entity = formatEntity(*node.Entity)
+	}
+	if parent == nil || !sameTarget(node, parent) {
+		step.Rule = e.rules[e.ruleKey(node)]
+	}
+
+	switch {
+	case node.Leaf != nil:
+		tuple := fmt.Sprintf("%s#%s@%s", step.Entity, step.Permission, formatSubject(e.subject))
+		for _, s := range node.Leaf.Subjects {
+			if s != nil && *s == e.subject {
+				step.Matched = true
+			}
+		}
+		if step.Matched {
+			step.Tuple = tuple
+		} else {
+			step.Missing = tuple
+		}
+	case node.Expand != nil:
+		step.Operation = node.Expand.Operation
+		for i, child := range node.Expand.Children {
+			if child == nil {
+				continue
+			}
+			childStep := e.step(child, node)
+			if child.Entity != nil && node.Entity != nil && *child.Entity != *node.Entity {
+				childStep.Via = via(node, child)
+			}
+			step.Children = append(step.Children, childStep)
+
+			switch node.Expand.Operation {
+			case ExpandIntersection:
+				step.Matched = childStep.Matched && (i == 0 || step.Matched)
+			case ExpandExclusion:
+				if i == 0 {
+					step.Matched = childStep.Matched
+				} else if childStep.Matched {
+					step.Matched = false
+				}
+			default:
+				step.Matched = step.Matched || childStep.Matched
+			}
+		}
+	}
+	return step
+}
+
+func (e explainer) ruleKey(node *ExpandTree) string {
+	if node.Entity == nil {
+		return ""
+	}
+	return node.Entity.Type + "#" + node.Permission
+}
+
+// sameTarget reports whether two nodes expand the same permission of the
+// same entity, as nested rewrites like (owner or member) do.
+func sameTarget(a, b *ExpandTree) bool {
+	return a.Entity != nil && b.Entity != nil && *a.Entity == *b.Entity && a.Permission == b.Permission
+}
+
+// via returns the tuple that leads from node to child, a subject set when the
+// node lists the child as one, e.g. project:1#team@team:2#member, and a
+// tuple to userset hop otherwise, e.g. team:7#org@organization:3.
+func via(node, child *ExpandTree) string {
+	target := formatEntity(*child.Entity)
+	if listsSubjectSet(node, child) {
+		target += "#" + child.Permission
+	}
+	return fmt.Sprintf("%s#%s@%s", formatEntity(*node.Entity), node.Permission, target)
+}
+
+// listsSubjectSet reports whether a leaf under node lists child as a
+// subject set, e.g. team:2#member.
+func listsSubjectSet(node, child *ExpandTree) bool {
+	set := ExpandSubject{Type: child.Entity.Type, Id: child.Entity.Id, Relation: child.Permission}
+	for _, sibling := range node.Expand.Children {
+		if sibling == nil || sibling.Leaf == nil {
+			continue
+		}
+		for _, s := range sibling.Leaf.Subjects {
+			if s != nil && *s == set {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatEntity(e Entity) string {
+	return e.Type + ":" + e.Id
+}
+
+func formatSubject(s ExpandSubject) string {
+	if s.Relation != "" {
+		return s.Type + ":" + s.Id + "#" + s.Relation
+	}
+	return s.Type + ":" + s.Id
+}
+
+var (
+	schemaEntityPattern     = regexp.MustCompile(`^entity\s+(\w+)\s*\{`)
+	schemaPermissionPattern = regexp.MustCompile(`^permission\s+(\w+)\s*=\s*(.+)$`)
+)
+
+// parseSchemaRules returns the rule of each permission of a schema written
+// in Permify's language, keyed by entity type#permission.
+func parseSchemaRules(schema string) map[string]string {
+	rules := make(map[string]string)
+	entity := ""
+	scanner := bufio.NewScanner(strings.NewReader(schema))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if m := schemaEntityPattern.FindStringSubmatch(line); m != nil {
+			entity = m[1]
+			continue
+		}
+		if m := schemaPermissionPattern.FindStringSubmatch(line); m != nil && entity != "" {
+			rules[entity+"#"+m[1]] = strings.Join(strings.Fields(m[2]), " ")
+		}
+	}
+	return rules
+}
+
+// JSON renders the explanation as indented JSON.
+func (e *Explanation) JSON() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// String renders the explanation as indented text, one step per line.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	verdict := "denied"
+	if e.Allowed {
+		verdict = "allowed"
+	}
+	fmt.Fprintf(&b, "%s %s %s on %s\n", e.Subject, verdict, e.Permission, e.Entity)
+	if e.Note != "" {
+		fmt.Fprintf(&b, "note: %s\n", e.Note)
+	}
+	e.Derivation.write(&b, 0)
+	return b.String()
+}
+
+func (s *ExplainStep) write(b *strings.Builder, depth int) {
+	if s == nil {
+		return
+	}
+	mark := "[-]"
+	if s.Matched {
+		mark = "[+]"
+	}
+	fmt.Fprintf(b, "%s%s %s %s", strings.Repeat("  ", depth), mark, s.Entity, s.Permission)
+	if s.Rule != "" {
+		fmt.Fprintf(b, " = %s", s.Rule)
+	}
+	if s.Via != "" {
+		fmt.Fprintf(b, " via %s", s.Via)
+	}
+	switch {
+	case s.Tuple != "":
+		fmt.Fprintf(b, ": %s", s.Tuple)
+	case s.Missing != "":
+		fmt.Fprintf(b, ": missing %s", s.Missing)
+	case s.Operation != "" && len(s.Children) == 0:
+		b.WriteString(": no tuples")
+	}
+	b.WriteString("\n")
+	for _, child := range s.Children {
+		child.write(b, depth+1)
+	}
+}
@@ -0,0 +1,171 @@
+package permify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const explainSchema = `
+entity user {}
+
+entity organization {
+	relation admin @user
+}
+
+entity team {
+	relation org @organization
+	relation owner @user
+	relation member @user
+
+	// organization admins or owners can edit the team
+	permission edit = org.admin or owner
+	permission invite = org.admin and (owner or member)
+}
+`
+
+// explainExpand expands team:7#edit, user:42 is an admin of organization:3.
+const explainExpand = `{"tree": {
+	"entity": {"type": "team", "id": "7"}, "permission": "edit",
+	"expand": {"operation": "OPERATION_UNION", "children": [
+		{"entity": {"type": "team", "id": "7"}, "permission": "org",
+			"expand": {"operation": "OPERATION_UNION", "children": [
+				{"entity": {"type": "organization", "id": "3"}, "permission": "admin",
+					"leaf": {"subjects": {"subjects": [{"type": "user", "id": "42"}]}}}
+			]}},
+		{"entity": {"type": "team", "id": "7"}, "permission": "owner",
+			"leaf": {"subjects": {"subjects": [{"type": "user", "id": "1"}]}}}
+	]}
+}}`
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	team := &permify.Entity{Type: "team", Id: "7"}
+	withSchema := func(config *permify.Config) {
+		config.Schema = explainSchema
+	}
+
+	t.Run("Granted", func(t *testing.T) {
+		client, rt := newTestClient(withSchema,
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`},
+			mockStep{status: http.StatusOK, body: explainExpand},
+		)
+
+		explanation, err := client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: "user", Id: "42"}, team, "edit")
+		require.NoError(t, err)
+		assert.Equal(t, "/v1/tenants/t1/permissions/check", rt.requests[0].URL.Path)
+		assert.Equal(t, "/v1/tenants/t1/permissions/expand", rt.requests[1].URL.Path)
+
+		assert.True(t, explanation.Allowed)
+		assert.Empty(t, explanation.Note)
+		assert.Equal(t, "org.admin or owner", explanation.Derivation.Rule)
+		admin := explanation.Derivation.Children[0].Children[0]
+		assert.Equal(t, "team:7#org@organization:3", admin.Via)
+		assert.Equal(t, "organization:3#admin@user:42", admin.Tuple)
+
+		assert.Equal(t, `user:42 allowed edit on team:7
+[+] team:7 edit = org.admin or owner
+  [+] team:7 org
+    [+] organization:3 admin via team:7#org@organization:3: organization:3#admin@user:42
+  [-] team:7 owner: missing team:7#owner@user:42
+`, explanation.String())
+
+		raw, err := explanation.JSON()
+		require.NoError(t, err)
+		var decoded permify.Explanation
+		require.NoError(t, json.Unmarshal(raw, &decoded))
+		assert.Equal(t, explanation, &decoded)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		client, _ := newTestClient(withSchema,
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_DENIED", "metadata": {}}`},
+			mockStep{status: http.StatusOK, body: explainExpand},
+		)
+
+		explanation, err := client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: "user", Id: "5"}, team, "edit")
+		require.NoError(t, err)
+		assert.False(t, explanation.Allowed)
+		assert.False(t, explanation.Derivation.Matched)
+		assert.Equal(t, `user:5 denied edit on team:7
+[-] team:7 edit = org.admin or owner
+  [-] team:7 org
+    [-] organization:3 admin via team:7#org@organization:3: missing organization:3#admin@user:5
+  [-] team:7 owner: missing team:7#owner@user:5
+`, explanation.String())
+	})
+
+	t.Run("Intersection And Exclusion", func(t *testing.T) {
+		client, _ := newTestClient(withSchema,
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_DENIED", "metadata": {}}`},
+			mockStep{status: http.StatusOK, body: `{"tree": {
+				"entity": {"type": "team", "id": "7"}, "permission": "invite",
+				"expand": {"operation": "OPERATION_INTERSECTION", "children": [
+					{"entity": {"type": "team", "id": "7"}, "permission": "owner",
+						"leaf": {"subjects": {"subjects": [{"type": "user", "id": "42"}]}}},
+					{"entity": {"type": "team", "id": "7"}, "permission": "invite",
+						"expand": {"operation": "OPERATION_EXCLUSION", "children": [
+							{"entity": {"type": "team", "id": "7"}, "permission": "member",
+								"leaf": {"subjects": {"subjects": [{"type": "user", "id": "42"}]}}},
+							{"entity": {"type": "team", "id": "7"}, "permission": "banned",
+								"leaf": {"subjects": {"subjects": [{"type": "user", "id": "42"}]}}}
+						]}}
+				]}
+			}}`},
+		)
+
+		explanation, err := client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: "user", Id: "42"}, team, "invite")
+		require.NoError(t, err)
+		assert.False(t, explanation.Derivation.Matched)
+		assert.Equal(t, "org.admin and (owner or member)", explanation.Derivation.Rule)
+		nested := explanation.Derivation.Children[1]
+		assert.Empty(t, nested.Rule)
+		assert.False(t, nested.Matched)
+		assert.True(t, nested.Children[0].Matched)
+		assert.True(t, nested.Children[1].Matched)
+	})
+
+	t.Run("Decision Not In Expansion", func(t *testing.T) {
+		client, _ := newTestClient(withSchema,
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`},
+			mockStep{status: http.StatusOK, body: explainExpand},
+		)
+
+		explanation, err := client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: "user", Id: "5"}, team, "edit")
+		require.NoError(t, err)
+		assert.True(t, explanation.Allowed)
+		assert.NotEmpty(t, explanation.Note)
+	})
+
+	t.Run("Rules From Saved Schema", func(t *testing.T) {
+		httpClient, _ := newSequenceClient(
+			mockStep{status: http.StatusOK, body: `{"schema_version": "v1"}`},
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`},
+			mockStep{status: http.StatusOK, body: explainExpand},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		client := permify.NewClient(config)
+
+		_, err := client.(permify.SchemaManagerClient).SaveModelSchema(ctx, &permify.SaveSchemaRequest{Schema: explainSchema})
+		require.NoError(t, err)
+		explanation, err := client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: "user", Id: "42"}, team, "edit")
+		require.NoError(t, err)
+		assert.Equal(t, "org.admin or owner", explanation.Derivation.Rule)
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		client, rt := newTestClient(withSchema, mockStep{status: http.StatusOK, body: `{}`})
+
+		_, err := client.(permify.ExplainClient).Explain(ctx, nil, team, "edit")
+		assert.Error(t, err)
+		_, err = client.(permify.ExplainClient).Explain(ctx, &permify.Subject{Type: "user", Id: "42"}, team, "")
+		assert.Error(t, err)
+		assert.Equal(t, 0, rt.Calls())
+	})
+}
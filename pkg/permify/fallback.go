@@ -0,0 +1,170 @@
+package permify
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const DefaultFallbackCacheSize = 10000
+
+// FailureMode selects how CheckPermission behaves when Permify is unavailable.
+type FailureMode int
+
+const (
+	FailClosed FailureMode = iota // Return the error, callers deny
+	FailOpen                      // Grant the allow-listed permissions
+	FailStale                     // Serve the last known decision if it is fresh enough
+)
+
+// CheckFallbackPolicy configures degraded mode for permission checks. It only
+// applies once retries are exhausted or the circuit breaker is open, and only
+// when the server is unavailable: validation errors and 4xx responses other
+// than 429 are always returned as errors.
+type CheckFallbackPolicy struct {
+	Mode FailureMode
+	// FailOpenPermissions are granted to everyone while failing open.
+	FailOpenPermissions []string
+	// MaxStaleness is the oldest cached decision FailStale may serve.
+	MaxStaleness time.Duration
+	// CacheSize bounds the number of decisions kept for FailStale,
+	// defaults to DefaultFallbackCacheSize.
+	CacheSize int
+}
+
+// CheckSource tells where a decision came from.
+type CheckSource string
+
+const (
+	CheckSourceServer     CheckSource = "server"
+	CheckSourceStaleCache CheckSource = "stale_cache"
+	CheckSourceFailOpen   CheckSource = "fail_open"
+)
+
+// CheckDecision is the outcome of a permission check.
+type CheckDecision struct {
+	Allowed       bool
+	CheckCount    int           // Checks the server ran to reach the decision
+	SnapToken     string        // Snapshot the check was evaluated at, empty for the latest
+	SchemaVersion string        // Schema version the check used, empty for the latest
+	Latency       time.Duration // Time taken including rate limiting, retries and fallback
+	FromCache     bool          // Set when the decision was served from the stale cache
+	Degraded      bool          // Set when the decision did not come from the server
+	Source        CheckSource   // Where the decision came from
+	Age           time.Duration // Age of a decision served from the stale cache
+	Cause         error         // Failure that led to a degraded decision
+}
+
+// isUnavailable reports whether err means the server could not answer, as
+// opposed to the caller giving up, the client's own rate limiter refusing
+// the call or the request being invalid.
+func isUnavailable(err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrRateLimitCancelled), errors.Is(err, ErrRateLimitDeadline),
+		errors.Is(err, ErrRateLimitWouldBlock), errors.Is(err, ErrRateLimitExceeded):
+		return false
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrRequestFailed):
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// cachedDecision is an entry of the stale decision cache.
+type cachedDecision struct {
+	key     string
+	allowed bool
+	at      time.Time
+}
+
+// checkFallback applies a CheckFallbackPolicy. A nil fallback fails closed.
+type checkFallback struct {
+	policy  *CheckFallbackPolicy
+	allowed map[string]bool
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently stored first
+}
+
+func newCheckFallback(policy *CheckFallbackPolicy) *checkFallback {
+	if policy == nil {
+		return nil
+	}
+	// the caller's policy may be shared, defaults go on a copy
+	resolved := *policy
+	if resolved.CacheSize <= 0 {
+		resolved.CacheSize = DefaultFallbackCacheSize
+	}
+	f := &checkFallback{
+		policy:  &resolved,
+		allowed: make(map[string]bool, len(policy.FailOpenPermissions)),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	for _, permission := range policy.FailOpenPermissions {
+		f.allowed[permission] = true
+	}
+	return f
+}
+
+// decisionKey identifies a check within a tenant.
+func decisionKey(tenant string, request *PermissionCheckRequest) string {
+	return fmt.Sprintf("%s/%s:%s#%s@%s:%s", tenant,
+		request.Entity.Type, request.Entity.Id, request.Permission,
+		request.Subject.Type, request.Subject.Id)
+}
+
+// remember stores a decision from the server for FailStale.
+func (f *checkFallback) remember(key string, allowed bool) {
+	if f == nil || f.policy.Mode != FailStale {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if element, ok := f.entries[key]; ok {
+		f.order.MoveToFront(element)
+		element.Value = &cachedDecision{key: key, allowed: allowed, at: time.Now()}
+		return
+	}
+	f.entries[key] = f.order.PushFront(&cachedDecision{key: key, allowed: allowed, at: time.Now()})
+	for f.order.Len() > f.policy.CacheSize {
+		oldest := f.order.Back()
+		f.order.Remove(oldest)
+		delete(f.entries, oldest.Value.(*cachedDecision).key)
+	}
+}
+
+// decide returns a degraded decision for a failed check, or nil when the
+// policy requires failing closed.
+func (f *checkFallback) decide(key, permission string, cause error) *CheckDecision {
+	if f == nil || !isUnavailable(cause) {
+		return nil
+	}
+
+	switch f.policy.Mode {
+	case FailOpen:
+		if f.allowed[permission] {
+			return &CheckDecision{Allowed: true, Degraded: true, Source: CheckSourceFailOpen, Cause: cause}
+		}
+	case FailStale:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if element, ok := f.entries[key]; ok {
+			cached := element.Value.(*cachedDecision)
+			if age := time.Since(cached.at); age <= f.policy.MaxStaleness {
+				return &CheckDecision{Allowed: cached.allowed, FromCache: true, Degraded: true, Source: CheckSourceStaleCache, Age: age, Cause: cause}
+			}
+		}
+	}
+	return nil
+}
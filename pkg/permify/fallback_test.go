@@ -0,0 +1,194 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFallback(t *testing.T) {
+	ctx := context.Background()
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+
+	newRequest := func(permission string) *permify.PermissionCheckRequest {
+		return &permify.PermissionCheckRequest{
+			Entity:     &permify.Entity{Type: "workspace", Id: "ws123"},
+			Permission: permission,
+			Subject:    &permify.Subject{Type: "user", Id: "user123"},
+		}
+	}
+	newFallbackClient := func(policy *permify.CheckFallbackPolicy, steps ...mockStep) permify.RelationshipClient {
+		httpClient, _ := newSequenceClient(steps...)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1000
+		config.CheckFallback = policy
+		return permify.NewClient(config)
+	}
+
+	t.Run("Fails Closed By Default", func(t *testing.T) {
+		client := newFallbackClient(nil, mockStep{status: http.StatusServiceUnavailable})
+
+		decision, err := client.Check(ctx, newRequest("view"))
+		assert.Error(t, err)
+		assert.Nil(t, decision)
+	})
+
+	t.Run("Server Decision", func(t *testing.T) {
+		client := newFallbackClient(nil, mockStep{status: http.StatusOK, body: allowed})
+
+		decision, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.False(t, decision.Degraded)
+		assert.Equal(t, permify.CheckSourceServer, decision.Source)
+	})
+
+	t.Run("Fails Open For Allow Listed Permissions", func(t *testing.T) {
+		policy := &permify.CheckFallbackPolicy{
+			Mode:                permify.FailOpen,
+			FailOpenPermissions: []string{"view"},
+		}
+		client := newFallbackClient(policy, mockStep{status: http.StatusBadGateway})
+		// defaults are not written back to the caller's policy
+		assert.Zero(t, policy.CacheSize)
+
+		decision, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.True(t, decision.Degraded)
+		assert.Equal(t, permify.CheckSourceFailOpen, decision.Source)
+		assert.ErrorIs(t, decision.Cause, permify.ErrUnableToCheckRelationship)
+
+		ok, err := client.CheckPermission(ctx, newRequest("view").Subject, newRequest("view").Entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = client.Check(ctx, newRequest("delete"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Client Errors Never Fall Back", func(t *testing.T) {
+		policy := &permify.CheckFallbackPolicy{
+			Mode:                permify.FailOpen,
+			FailOpenPermissions: []string{"view"},
+		}
+		client := newFallbackClient(policy, mockStep{status: http.StatusBadRequest, body: `{"code":3,"message":"bad"}`})
+
+		_, err := client.Check(ctx, newRequest("view"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Caller Giving Up Never Falls Back", func(t *testing.T) {
+		policy := &permify.CheckFallbackPolicy{
+			Mode:                permify.FailOpen,
+			FailOpenPermissions: []string{"view"},
+		}
+		client := newFallbackClient(policy, mockStep{err: context.Canceled})
+		_, err := client.Check(ctx, newRequest("view"))
+		assert.ErrorIs(t, err, context.Canceled)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = client.Check(cancelled, newRequest("view"))
+		assert.ErrorIs(t, err, permify.ErrRateLimitCancelled)
+	})
+
+	t.Run("Rate Limiter Rejections Never Fall Back", func(t *testing.T) {
+		httpClient, _ := newSequenceClient(mockStep{status: http.StatusOK, body: allowed})
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1
+		config.NonBlocking = true
+		config.CheckFallback = &permify.CheckFallbackPolicy{
+			Mode:                permify.FailOpen,
+			FailOpenPermissions: []string{"view"},
+		}
+		client := permify.NewClient(config)
+
+		// the first check spends the only token
+		_, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		decision, err := client.Check(ctx, newRequest("view"))
+		assert.ErrorIs(t, err, permify.ErrRateLimitWouldBlock)
+		assert.Nil(t, decision)
+	})
+
+	t.Run("Serves Stale Decision", func(t *testing.T) {
+		policy := &permify.CheckFallbackPolicy{
+			Mode:         permify.FailStale,
+			MaxStaleness: time.Minute,
+		}
+		client := newFallbackClient(policy,
+			mockStep{status: http.StatusOK, body: allowed},
+			mockStep{status: http.StatusServiceUnavailable},
+		)
+
+		decision, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		assert.False(t, decision.Degraded)
+
+		decision, err = client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.True(t, decision.Degraded)
+		assert.Equal(t, permify.CheckSourceStaleCache, decision.Source)
+		assert.True(t, decision.FromCache)
+		assert.Less(t, decision.Age, time.Minute)
+
+		// nothing is cached for another permission
+		_, err = client.Check(ctx, newRequest("edit"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Stale Decision Too Old", func(t *testing.T) {
+		policy := &permify.CheckFallbackPolicy{
+			Mode:         permify.FailStale,
+			MaxStaleness: time.Millisecond,
+		}
+		client := newFallbackClient(policy,
+			mockStep{status: http.StatusOK, body: allowed},
+			mockStep{status: http.StatusServiceUnavailable},
+		)
+
+		_, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		_, err = client.Check(ctx, newRequest("view"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Composes With Retries And Circuit Breaker", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusOK, body: allowed},
+			mockStep{status: http.StatusServiceUnavailable},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1000
+		config.Retry = fastRetryPolicy()
+		config.CircuitBreaker = &permify.CircuitBreakerConfig{MinRequests: 3, OpenDuration: time.Minute}
+		config.CheckFallback = &permify.CheckFallbackPolicy{Mode: permify.FailStale, MaxStaleness: time.Minute}
+		client := permify.NewClient(config)
+
+		_, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+
+		// the retries trip the breaker, the stale decision still answers
+		decision, err := client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		assert.True(t, decision.Degraded)
+		assert.ErrorIs(t, decision.Cause, permify.ErrCircuitOpen)
+		assert.Equal(t, 3, rt.Calls())
+
+		// the open circuit stops further calls to the server
+		decision, err = client.Check(ctx, newRequest("view"))
+		assert.NoError(t, err)
+		assert.True(t, decision.Degraded)
+		assert.Equal(t, 3, rt.Calls())
+	})
+}
@@ -0,0 +1,225 @@
+package permify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Graph is a picture of relationships between entities, rendered to
+// Graphviz DOT or Mermaid. Entities are clustered by type and edges point
+// from an entity to the subject of a relationship, labelled with the
+// relation. Build one with NewRelationshipGraph or NewExpandGraph.
+type Graph struct {
+	nodes     []graphNode
+	nodeIndex map[string]int
+	edges     []graphEdge
+	edgeIndex map[string]int
+}
+
+type graphNode struct {
+	key         string // type:id
+	entityType  string
+	id          string
+	highlighted bool
+}
+
+type graphEdge struct {
+	from, to    int
+	label       string
+	highlighted bool
+}
+
+// GraphHighlightColor is the color of the nodes and edges that grant a
+// permission.
+const GraphHighlightColor = "#d62728"
+
+func newGraph() *Graph {
+	return &Graph{nodeIndex: make(map[string]int), edgeIndex: make(map[string]int)}
+}
+
+// NewRelationshipGraph returns the graph of relationships, e.g. those read
+// from a tenant with ReadRelationships.
+func NewRelationshipGraph(relationships []*Relationship) *Graph {
+	g := newGraph()
+	for _, r := range relationships {
+		if r == nil || r.Entity == nil || r.Subject == nil {
+			continue
+		}
+		g.addEdge(*r.Entity, r.Relation, ExpandSubject{Type: r.Subject.Type, Id: r.Subject.Id})
+	}
+	return g
+}
+
+// NewExpandGraph returns the graph of the relationships an expand tree is
+// made of: the tuples of its leaves and the tuple to userset hops between
+// entities.
+func NewExpandGraph(tree *ExpandTree) *Graph {
+	g := newGraph()
+	if tree != nil && tree.Entity != nil {
+		g.addNode(tree.Entity.Type, tree.Entity.Id)
+	}
+	tree.Walk(func(node *ExpandTree, _ int) bool {
+		if node.Entity == nil {
+			return true
+		}
+		if node.Leaf != nil {
+			for _, s := range node.Leaf.Subjects {
+				if s != nil {
+					g.addEdge(*node.Entity, node.Permission, *s)
+				}
+			}
+		}
+		if node.Expand != nil {
+			for _, child := range node.Expand.Children {
+				if child == nil || child.Entity == nil || *child.Entity == *node.Entity {
+					continue
+				}
+				// subject sets are drawn by the leaf that lists them
+				if !listsSubjectSet(node, child) {
+					g.addEdge(*node.Entity, node.Permission, ExpandSubject{Type: child.Entity.Type, Id: child.Entity.Id})
+				}
+			}
+		}
+		return true
+	})
+	return g
+}
+
+// HighlightGrant highlights the relationships through which the explanation
+// grants its permission. It does nothing for denials.
+func (g *Graph) HighlightGrant(explanation *Explanation) {
+	if explanation == nil || explanation.Derivation == nil || !explanation.Derivation.Matched {
+		return
+	}
+	var highlight func(step *ExplainStep)
+	highlight = func(step *ExplainStep) {
+		if !step.Matched {
+			return
+		}
+		for _, tuple := range []string{step.Via, step.Tuple} {
+			if i, ok := g.edgeIndex[tuple]; tuple != "" && ok {
+				edge := &g.edges[i]
+				edge.highlighted = true
+				g.nodes[edge.from].highlighted = true
+				g.nodes[edge.to].highlighted = true
+			}
+		}
+		for _, child := range step.Children {
+			highlight(child)
+		}
+	}
+	highlight(explanation.Derivation)
+}
+
+func (g *Graph) addNode(entityType, id string) int {
+	key := formatEntity(Entity{Type: entityType, Id: id})
+	if i, ok := g.nodeIndex[key]; ok {
+		return i
+	}
+	g.nodes = append(g.nodes, graphNode{key: key, entityType: entityType, id: id})
+	g.nodeIndex[key] = len(g.nodes) - 1
+	return len(g.nodes) - 1
+}
+
+func (g *Graph) addEdge(entity Entity, relation string, subject ExpandSubject) {
+	key := fmt.Sprintf("%s#%s@%s", formatEntity(entity), relation, formatSubject(subject))
+	if _, ok := g.edgeIndex[key]; ok {
+		return
+	}
+	label := relation
+	if subject.Relation != "" {
+		label += " (" + subject.Relation + ")"
+	}
+	g.edges = append(g.edges, graphEdge{
+		from:  g.addNode(entity.Type, entity.Id),
+		to:    g.addNode(subject.Type, subject.Id),
+		label: label,
+	})
+	g.edgeIndex[key] = len(g.edges) - 1
+}
+
+// clusters returns the nodes of each entity type, types in the order they
+// first appear.
+func (g *Graph) clusters() (types []string, members map[string][]int) {
+	members = make(map[string][]int)
+	for i, n := range g.nodes {
+		if _, ok := members[n.entityType]; !ok {
+			types = append(types, n.entityType)
+		}
+		members[n.entityType] = append(members[n.entityType], i)
+	}
+	return types, members
+}
+
+// WriteDOT renders the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	highlight := fmt.Sprintf(`color=%q, fontcolor=%q, penwidth=2`, GraphHighlightColor, GraphHighlightColor)
+
+	fmt.Fprintln(b, "digraph permify {")
+	fmt.Fprintln(b, "\trankdir=LR;")
+	fmt.Fprintln(b, "\tnode [shape=box];")
+	types, members := g.clusters()
+	for _, t := range types {
+		fmt.Fprintf(b, "\tsubgraph %s {\n", strconv.Quote("cluster_"+t))
+		fmt.Fprintf(b, "\t\tlabel=%s;\n", strconv.Quote(t))
+		for _, i := range members[t] {
+			n := g.nodes[i]
+			fmt.Fprintf(b, "\t\t%s [label=%s", strconv.Quote(n.key), strconv.Quote(n.id))
+			if n.highlighted {
+				fmt.Fprintf(b, ", %s", highlight)
+			}
+			fmt.Fprintln(b, "];")
+		}
+		fmt.Fprintln(b, "\t}")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(b, "\t%s -> %s [label=%s", strconv.Quote(g.nodes[e.from].key), strconv.Quote(g.nodes[e.to].key), strconv.Quote(e.label))
+		if e.highlighted {
+			fmt.Fprintf(b, ", %s", highlight)
+		}
+		fmt.Fprintln(b, "];")
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// WriteMermaid renders the graph as a Mermaid flowchart.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	quote := strings.NewReplacer(`"`, "#quot;")
+
+	fmt.Fprintln(b, "flowchart LR")
+	types, members := g.clusters()
+	for c, t := range types {
+		fmt.Fprintf(b, "\tsubgraph c%d [\"%s\"]\n", c, quote.Replace(t))
+		for _, i := range members[t] {
+			fmt.Fprintf(b, "\t\tn%d[\"%s\"]\n", i, quote.Replace(g.nodes[i].id))
+		}
+		fmt.Fprintln(b, "\tend")
+	}
+	var links []string
+	for i, e := range g.edges {
+		fmt.Fprintf(b, "\tn%d -->|\"%s\"| n%d\n", e.from, quote.Replace(e.label), e.to)
+		if e.highlighted {
+			links = append(links, strconv.Itoa(i))
+		}
+	}
+	var nodes []string
+	for i, n := range g.nodes {
+		if n.highlighted {
+			nodes = append(nodes, "n"+strconv.Itoa(i))
+		}
+	}
+	if len(nodes) > 0 {
+		fmt.Fprintf(b, "\tclassDef granted stroke:%s,stroke-width:3px\n", GraphHighlightColor)
+		fmt.Fprintf(b, "\tclass %s granted\n", strings.Join(nodes, ","))
+	}
+	if len(links) > 0 {
+		fmt.Fprintf(b, "\tlinkStyle %s stroke:%s,stroke-width:3px\n", strings.Join(links, ","), GraphHighlightColor)
+	}
+	return b.Flush()
+}
@@ -0,0 +1,116 @@
+package permify_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph(t *testing.T) {
+	relationships := []*permify.Relationship{
+		{Entity: &permify.Entity{Type: "team", Id: "7"}, Relation: "org", Subject: &permify.Subject{Type: "organization", Id: "3"}},
+		{Entity: &permify.Entity{Type: "organization", Id: "3"}, Relation: "admin", Subject: &permify.Subject{Type: "user", Id: "42"}},
+		{Entity: &permify.Entity{Type: "team", Id: "7"}, Relation: "owner", Subject: &permify.Subject{Type: "user", Id: "1"}},
+		{Entity: &permify.Entity{Type: "team", Id: "7"}, Relation: "owner", Subject: &permify.Subject{Type: "user", Id: "1"}},
+	}
+	var response struct {
+		Tree permify.ExpandTree `json:"tree"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(explainExpand), &response))
+	grant := &permify.Explanation{Allowed: true, Derivation: &permify.ExplainStep{
+		Matched: true,
+		Children: []*permify.ExplainStep{{
+			Matched: true,
+			Children: []*permify.ExplainStep{{
+				Matched: true,
+				Via:     "team:7#org@organization:3",
+				Tuple:   "organization:3#admin@user:42",
+			}},
+		}, {
+			Missing: "team:7#owner@user:42",
+		}},
+	}}
+
+	t.Run("DOT", func(t *testing.T) {
+		g := permify.NewRelationshipGraph(relationships)
+		g.HighlightGrant(grant)
+
+		var out bytes.Buffer
+		require.NoError(t, g.WriteDOT(&out))
+		assert.Equal(t, `digraph permify {
+	rankdir=LR;
+	node [shape=box];
+	subgraph "cluster_team" {
+		label="team";
+		"team:7" [label="7", color="#d62728", fontcolor="#d62728", penwidth=2];
+	}
+	subgraph "cluster_organization" {
+		label="organization";
+		"organization:3" [label="3", color="#d62728", fontcolor="#d62728", penwidth=2];
+	}
+	subgraph "cluster_user" {
+		label="user";
+		"user:42" [label="42", color="#d62728", fontcolor="#d62728", penwidth=2];
+		"user:1" [label="1"];
+	}
+	"team:7" -> "organization:3" [label="org", color="#d62728", fontcolor="#d62728", penwidth=2];
+	"organization:3" -> "user:42" [label="admin", color="#d62728", fontcolor="#d62728", penwidth=2];
+	"team:7" -> "user:1" [label="owner"];
+}
+`, out.String())
+	})
+
+	t.Run("Mermaid", func(t *testing.T) {
+		g := permify.NewRelationshipGraph(relationships)
+		g.HighlightGrant(grant)
+
+		var out bytes.Buffer
+		require.NoError(t, g.WriteMermaid(&out))
+		assert.Equal(t, `flowchart LR
+	subgraph c0 ["team"]
+		n0["7"]
+	end
+	subgraph c1 ["organization"]
+		n1["3"]
+	end
+	subgraph c2 ["user"]
+		n2["42"]
+		n3["1"]
+	end
+	n0 -->|"org"| n1
+	n1 -->|"admin"| n2
+	n0 -->|"owner"| n3
+	classDef granted stroke:#d62728,stroke-width:3px
+	class n0,n1,n2 granted
+	linkStyle 0,1 stroke:#d62728,stroke-width:3px
+`, out.String())
+	})
+
+	t.Run("Expand Tree", func(t *testing.T) {
+		var fromRelationships, fromTree bytes.Buffer
+		require.NoError(t, permify.NewRelationshipGraph(relationships[:3]).WriteDOT(&fromRelationships))
+		require.NoError(t, permify.NewExpandGraph(&response.Tree).WriteDOT(&fromTree))
+		assert.Equal(t, fromRelationships.String(), fromTree.String())
+	})
+
+	t.Run("Subject Sets", func(t *testing.T) {
+		tree := &permify.ExpandTree{
+			Entity: &permify.Entity{Type: "project", Id: "1"}, Permission: "team",
+			Expand: &permify.ExpandNode{Operation: permify.ExpandUnion, Children: []*permify.ExpandTree{
+				{Entity: &permify.Entity{Type: "project", Id: "1"}, Permission: "team",
+					Leaf: &permify.ExpandLeaf{Subjects: []*permify.ExpandSubject{{Type: "team", Id: "2", Relation: "member"}}}},
+				{Entity: &permify.Entity{Type: "team", Id: "2"}, Permission: "member",
+					Leaf: &permify.ExpandLeaf{Subjects: []*permify.ExpandSubject{{Type: "user", Id: "42"}}}},
+			}},
+		}
+		var out bytes.Buffer
+		require.NoError(t, permify.NewExpandGraph(tree).WriteMermaid(&out))
+		assert.Contains(t, out.String(), `n0 -->|"team (member)"| n1`)
+		assert.Contains(t, out.String(), `n1 -->|"member"| n2`)
+		assert.NotContains(t, out.String(), `-->|"team"|`)
+	})
+}
@@ -0,0 +1,159 @@
+package permify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces redacted values in request and response bodies.
+const RedactedValue = "[REDACTED]"
+
+// Request is a call on its way to Permify, as seen by interceptors.
+type Request struct {
+	Operation Operation   // Operation being performed
+	Tenant    string      // Tenant the call is made for
+	Method    string      // HTTP method
+	URL       string      // Full endpoint URL
+	Payload   interface{} // Payload as passed by the caller, nil if there is none
+	Body      []byte      // JSON encoded payload sent with every attempt
+	Header    http.Header // Extra headers sent with every attempt
+	Redactor  Redactor    // Applied to bodies before they are logged or exported
+}
+
+// Redact returns body passed through the request's Redactor, if any.
+func (r *Request) Redact(body []byte) []byte {
+	if r.Redactor == nil {
+		return body
+	}
+	return r.Redactor(body)
+}
+
+// Response is the raw answer of Permify, as seen by interceptors.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Attempts   int // Attempts it took to get this response
+}
+
+// Handler performs a call. The response is non-nil whenever the server
+// answered, even if the error reports a non 2xx status.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Interceptor wraps a call. It may inspect or modify the request, call next
+// zero or more times and inspect or replace the response.
+type Interceptor func(ctx context.Context, req *Request, next Handler) (*Response, error)
+
+// chain builds a Handler that runs the interceptors in order around final.
+func chain(interceptors []Interceptor, final Handler) Handler {
+	handler := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req *Request) (*Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return handler
+}
+
+// Redactor masks sensitive data in a request or response body.
+type Redactor func(body []byte) []byte
+
+// RedactFields returns a Redactor replacing the values of the given JSON keys,
+// at any depth, with RedactedValue. Bodies that are not JSON are replaced as
+// a whole, since their content cannot be inspected.
+func RedactFields(fields ...string) Redactor {
+	redacted := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		redacted[field] = true
+	}
+
+	var walk func(value interface{}) interface{}
+	walk = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if redacted[key] {
+					v[key] = RedactedValue
+				} else {
+					v[key] = walk(child)
+				}
+			}
+		case []interface{}:
+			for i, child := range v {
+				v[i] = walk(child)
+			}
+		}
+		return value
+	}
+
+	return func(body []byte) []byte {
+		if len(body) == 0 {
+			return body
+		}
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return []byte(RedactedValue)
+		}
+		out, err := json.Marshal(walk(value))
+		if err != nil {
+			return []byte(RedactedValue)
+		}
+		return out
+	}
+}
+
+// NewRedactionInterceptor sets the Redactor used by the interceptors that run
+// after it. A Redactor set by an earlier interceptor is applied first.
+func NewRedactionInterceptor(redactor Redactor) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		if previous := req.Redactor; previous != nil {
+			req.Redactor = func(body []byte) []byte {
+				return redactor(previous(body))
+			}
+		} else {
+			req.Redactor = redactor
+		}
+		return next(ctx, req)
+	}
+}
+
+// NewLoggingInterceptor logs every call as a structured logrus entry. Failed
+// calls are logged at warning level, others at debug level. With logBodies the
+// request and response bodies are added, passed through the request's Redactor.
+func NewLoggingInterceptor(logger logrus.FieldLogger, logBodies bool) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+
+		fields := logrus.Fields{
+			"operation": req.Operation,
+			"tenant":    req.Tenant,
+			"method":    req.Method,
+			"url":       req.URL,
+			"duration":  time.Since(start),
+		}
+		if logBodies {
+			fields["request"] = string(req.Redact(req.Body))
+		}
+		if resp != nil {
+			fields["status"] = resp.StatusCode
+			fields["attempts"] = resp.Attempts
+			if logBodies {
+				fields["response"] = string(req.Redact(resp.Body))
+			}
+		}
+
+		entry := logger.WithFields(fields)
+		if err != nil {
+			entry.WithError(err).Warn("permify request failed")
+		} else {
+			entry.Debug("permify request")
+		}
+		return resp, err
+	}
+}
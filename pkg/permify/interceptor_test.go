@@ -0,0 +1,134 @@
+package permify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptors(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+
+	withInterceptors := func(interceptors []permify.Interceptor) func(*permify.Config) {
+		return func(config *permify.Config) {
+			config.Interceptors = interceptors
+		}
+	}
+
+	t.Run("Run In Order And See The Call", func(t *testing.T) {
+		var calls []string
+		record := func(name string) permify.Interceptor {
+			return func(ctx context.Context, req *permify.Request, next permify.Handler) (*permify.Response, error) {
+				calls = append(calls, name+" before")
+				assert.Equal(t, permify.OperationCheck, req.Operation)
+				assert.Equal(t, "t1", req.Tenant)
+				assert.IsType(t, &permify.PermissionCheckRequest{}, req.Payload)
+				resp, err := next(ctx, req)
+				calls = append(calls, name+" after")
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.JSONEq(t, allowed, string(resp.Body))
+				return resp, err
+			}
+		}
+		client, _ := newTestClient(withInterceptors([]permify.Interceptor{record("outer"), record("inner")}),
+			mockStep{status: http.StatusOK, body: allowed})
+
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, calls)
+	})
+
+	t.Run("Header Injection", func(t *testing.T) {
+		inject := func(ctx context.Context, req *permify.Request, next permify.Handler) (*permify.Response, error) {
+			req.Header.Set("X-Request-Id", "abc")
+			return next(ctx, req)
+		}
+		client, rt := newTestClient(withInterceptors([]permify.Interceptor{inject}), mockStep{status: http.StatusOK, body: allowed})
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", rt.requests[0].Header.Get("X-Request-Id"))
+		assert.Equal(t, permify.ContentTypeJSON, rt.requests[0].Header.Get(permify.ContentTypeHeader))
+	})
+
+	t.Run("Fault Injection", func(t *testing.T) {
+		injected := errors.New("injected fault")
+		fault := func(ctx context.Context, req *permify.Request, next permify.Handler) (*permify.Response, error) {
+			return nil, injected
+		}
+		client, rt := newTestClient(withInterceptors([]permify.Interceptor{fault}), mockStep{status: http.StatusOK, body: allowed})
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, injected)
+		assert.Equal(t, 0, rt.Calls())
+	})
+
+	t.Run("Sees Error Responses", func(t *testing.T) {
+		var status int
+		observe := func(ctx context.Context, req *permify.Request, next permify.Handler) (*permify.Response, error) {
+			resp, err := next(ctx, req)
+			status = resp.StatusCode
+			return resp, err
+		}
+		client, _ := newTestClient(withInterceptors([]permify.Interceptor{observe}), mockStep{status: http.StatusBadRequest, body: `{"code":3}`})
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Structured Logging With Redaction", func(t *testing.T) {
+		logger, hook := logtest.NewNullLogger()
+		logger.SetLevel(logrus.DebugLevel)
+		client, _ := newTestClient(withInterceptors([]permify.Interceptor{
+			permify.NewRedactionInterceptor(permify.RedactFields("id")),
+			permify.NewLoggingInterceptor(logger, true),
+		}), mockStep{status: http.StatusOK, body: allowed})
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+
+		entry := hook.LastEntry()
+		assert.Equal(t, logrus.DebugLevel, entry.Level)
+		assert.Equal(t, permify.OperationCheck, entry.Data["operation"])
+		assert.Equal(t, http.StatusOK, entry.Data["status"])
+		assert.Equal(t, 1, entry.Data["attempts"])
+		assert.NotContains(t, entry.Data["request"], "user123")
+		assert.Contains(t, entry.Data["request"], permify.RedactedValue)
+		assert.Contains(t, entry.Data["response"], "CHECK_RESULT_ALLOWED")
+	})
+
+	t.Run("Logs Failures As Warnings", func(t *testing.T) {
+		logger, hook := logtest.NewNullLogger()
+		client, _ := newTestClient(withInterceptors([]permify.Interceptor{
+			permify.NewLoggingInterceptor(logger, false),
+		}), mockStep{status: http.StatusServiceUnavailable})
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.Error(t, err)
+		entry := hook.LastEntry()
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		assert.NotNil(t, entry.Data[logrus.ErrorKey])
+		assert.NotContains(t, entry.Data, "request")
+	})
+}
+
+func TestRedactFields(t *testing.T) {
+	redact := permify.RedactFields("id", "token")
+
+	out := redact([]byte(`{"entity":{"type":"doc","id":"doc_1"},"tuples":[{"subject":{"id":"user_1"}}],"token":"x"}`))
+	assert.JSONEq(t, `{"entity":{"type":"doc","id":"[REDACTED]"},"tuples":[{"subject":{"id":"[REDACTED]"}}],"token":"[REDACTED]"}`, string(out))
+
+	assert.Equal(t, permify.RedactedValue, string(redact([]byte("<html>secret</html>"))))
+	assert.Empty(t, redact(nil))
+}
@@ -12,12 +12,21 @@ import (
 // LookupRelationshipResponse structure. If the lookup fails or the relationship
 // is not found, an error is returned.
 func (c *client) LookupRelationship(ctx context.Context, request *LookupRelationshipRequest) (*LookupRelationshipResponse, error) {
-	// hard limit depth of relationship graph
-	// should only be 3 deep at the present time
-	request.Metadata.Depth = 100
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if request.Subject == nil || request.Subject.Type == "" || request.Subject.Id == "" {
+		return nil, fmt.Errorf("invalid subject")
+	}
+	if err := validateContext(request.Context); err != nil {
+		return nil, err
+	}
+	resolved := *request
+	resolved.Metadata = c.metadata(ctx, request.Metadata)
+
 	url := c.constructURL(LookupRelationshipAPIPath)
 
-	body, err := c.sendRequest(ctx, http.MethodPost, url, request)
+	body, err := c.sendRequest(ctx, OperationLookup, http.MethodPost, url, &resolved)
 	if err != nil {
 		return nil, fmt.Errorf("permify request failed: %w", err)
 	}
@@ -28,7 +37,48 @@ func (c *client) LookupRelationship(ctx context.Context, request *LookupRelation
 	}
 
 	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
-		return nil, ErrUnableToLookupRelationship
+		return nil, responseError(OperationLookup, url, response.ErrorResponse)
+	}
+
+	return &response, nil
+}
+
+// LookupSubject retrieves the IDs of the subjects of the referenced type that
+// have the permission on the entity. The response's ContinuousToken is set
+// when there are more pages. If the lookup fails, an error is returned.
+func (c *client) LookupSubject(ctx context.Context, request *LookupSubjectRequest) (*LookupSubjectResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if request.Entity == nil || request.Entity.Type == "" || request.Entity.Id == "" {
+		return nil, fmt.Errorf("invalid entity")
+	}
+	if request.Permission == "" {
+		return nil, fmt.Errorf("permission is missing")
+	}
+	if request.SubjectReference.Type == "" {
+		return nil, fmt.Errorf("subject type is missing")
+	}
+	if err := validateContext(request.Context); err != nil {
+		return nil, err
+	}
+	resolved := *request
+	resolved.Metadata = c.metadata(ctx, request.Metadata)
+
+	url := c.constructURL(LookupSubjectAPIPath)
+
+	body, err := c.sendRequest(ctx, OperationLookupSubject, http.MethodPost, url, &resolved)
+	if err != nil {
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response LookupSubjectResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse lookup subject response: %w", err)
+	}
+
+	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
+		return nil, responseError(OperationLookupSubject, url, response.ErrorResponse)
 	}
 
 	return &response, nil
@@ -84,4 +84,78 @@ func TestLookupRelationship(t *testing.T) {
 		assert.Nil(t, err)
 		assert.NotNil(t, resp)
 	})
+
+	t.Run("Missing Subject", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient(`{}`, http.StatusOK)
+		client := permify.NewClient(config)
+
+		for _, subject := range []*permify.Subject{nil, {Type: "user"}} {
+			_, err := client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{
+				EntityType: "doc",
+				Permission: "read",
+				Subject:    subject,
+			})
+			assert.EqualError(t, err, "invalid subject")
+		}
+	})
+}
+
+func TestLookupSubject(t *testing.T) {
+	ctx := context.Background()
+	entity := &permify.Entity{Type: "project", Id: "org.7"}
+
+	t.Run("Successful Lookup Subject", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"subject_ids": ["org_alice", "bob"], "continuous_token": "next"}`})
+
+		resp, err := client.LookupSubject(permify.WithSnapToken(ctx, "snap1"), &permify.LookupSubjectRequest{
+			Metadata:         permify.Metadata{Depth: 5},
+			Entity:           entity,
+			Permission:       "edit",
+			SubjectReference: permify.SubjectReference{Type: "team", Relation: "member"},
+			PageSize:         10,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"org.alice", "bob"}, resp.SubjectIDs)
+		assert.Equal(t, "next", resp.ContinuousToken)
+		assert.Equal(t, "org.7", entity.Id)
+
+		assert.Equal(t, "/v1/tenants/t1/permissions/lookup-subject", rt.requests[0].URL.Path)
+		assert.Equal(t, permify.Metadata{Snap: "snap1", Depth: 5}, sentMetadata(t, rt.requests[0]))
+		var sent struct {
+			Entity           permify.Entity           `json:"entity"`
+			SubjectReference permify.SubjectReference `json:"subject_reference"`
+			PageSize         int                      `json:"page_size"`
+		}
+		decodeSent(t, rt.requests[0], &sent)
+		assert.Equal(t, permify.SubjectReference{Type: "team", Relation: "member"}, sent.SubjectReference)
+		assert.Equal(t, 10, sent.PageSize)
+	})
+
+	t.Run("Invalid Request", func(t *testing.T) {
+		client, rt := newTestClient(nil, mockStep{status: http.StatusOK, body: `{}`})
+
+		for _, req := range []*permify.LookupSubjectRequest{
+			nil,
+			{Permission: "edit", SubjectReference: permify.SubjectReference{Type: "user"}},
+			{Entity: &permify.Entity{Type: "project"}, Permission: "edit", SubjectReference: permify.SubjectReference{Type: "user"}},
+			{Entity: entity, SubjectReference: permify.SubjectReference{Type: "user"}},
+			{Entity: entity, Permission: "edit"},
+		} {
+			_, err := client.LookupSubject(ctx, req)
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 0, rt.Calls())
+	})
+
+	t.Run("Server Returns Error", func(t *testing.T) {
+		client, _ := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"code": 5, "message": "entity definition not found"}`})
+
+		_, err := client.LookupSubject(ctx, &permify.LookupSubjectRequest{
+			Entity:           entity,
+			Permission:       "edit",
+			SubjectReference: permify.SubjectReference{Type: "user"},
+		})
+		assert.ErrorIs(t, err, permify.ErrUnableToLookupSubject)
+	})
 }
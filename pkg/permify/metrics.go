@@ -0,0 +1,237 @@
+package permify
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Prometheus text exposition content type served by Metrics.
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency histogram.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Error classes recorded by Metrics.
+const (
+	ErrorClassRateLimited     = "rate_limited"        // the client's own rate limiter can never grant a token
+	ErrorClassRateLimitWait   = "rate_limit_deadline" // the caller's deadline passes before a rate limiter token
+	ErrorClassRateLimitNoWait = "would_block"         // the caller opted out of waiting for a rate limiter token
+	ErrorClassCancelled       = "cancelled"           // the caller's context ended
+	ErrorClassCircuitOpen     = "circuit_open"        // the circuit breaker rejected the call
+	ErrorClassThrottled       = "throttled"           // the server answered 429
+	ErrorClassServer          = "server"              // the server answered 5xx
+	ErrorClassClient          = "client"              // the server answered another 4xx
+	ErrorClassTransport       = "transport"           // the server could not be reached
+	ErrorClassOther           = "other"
+)
+
+// Metrics records per operation request counts, error classes, retries, rate
+// limiter waits and latencies, and renders them in the Prometheus text
+// exposition format. It is safe for concurrent use and may be shared by
+// several clients.
+type Metrics struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	operations map[Operation]*operationMetrics
+}
+
+// operationMetrics holds the metrics of one operation.
+type operationMetrics struct {
+	requests    uint64
+	errors      map[string]uint64
+	retries     uint64
+	waitSeconds float64
+	latency     []uint64 // cumulative counts per bucket
+	latencySum  float64
+	latencyN    uint64
+}
+
+// NewMetrics returns an empty Metrics. Without buckets DefaultLatencyBuckets is used.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Metrics{buckets: sorted, operations: make(map[Operation]*operationMetrics)}
+}
+
+// operation returns the metrics of op, the caller must hold the lock.
+func (m *Metrics) operation(op Operation) *operationMetrics {
+	o, ok := m.operations[op]
+	if !ok {
+		o = &operationMetrics{errors: make(map[string]uint64), latency: make([]uint64, len(m.buckets))}
+		m.operations[op] = o
+	}
+	return o
+}
+
+// observeRequest records a finished call. It is a no-op on nil Metrics.
+func (m *Metrics) observeRequest(op Operation, latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	seconds := latency.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o := m.operation(op)
+	o.requests++
+	if err != nil {
+		o.errors[errorClass(err)]++
+	}
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			o.latency[i]++
+		}
+	}
+	o.latencySum += seconds
+	o.latencyN++
+}
+
+// observeRetry records a retried attempt.
+func (m *Metrics) observeRetry(op Operation) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operation(op).retries++
+}
+
+// observeWait records the time a call waited for the rate limiter.
+func (m *Metrics) observeWait(op Operation, wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operation(op).waitSeconds += wait.Seconds()
+}
+
+// errorClass maps an error returned by the client to its class.
+func errorClass(err error) string {
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrRateLimitCancelled), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCancelled
+	case errors.Is(err, ErrRateLimitDeadline):
+		return ErrorClassRateLimitWait
+	case errors.Is(err, ErrRateLimitWouldBlock):
+		return ErrorClassRateLimitNoWait
+	case errors.Is(err, ErrRateLimitExceeded):
+		return ErrorClassRateLimited
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorClassCircuitOpen
+	case errors.Is(err, ErrRequestFailed):
+		return ErrorClassTransport
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorClassThrottled
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			return ErrorClassServer
+		case apiErr.StatusCode >= http.StatusBadRequest:
+			return ErrorClassClient
+		}
+	}
+	return ErrorClassOther
+}
+
+// ServeHTTP renders the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(ContentTypeHeader, MetricsContentType)
+	if _, err := m.WriteTo(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops := make([]Operation, 0, len(m.operations))
+	for op := range m.operations {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	header := func(name, kind, help string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("permify_client_requests_total", "counter", "Calls made by the Permify client.")
+	for _, op := range ops {
+		fmt.Fprintf(cw, "permify_client_requests_total{operation=%q} %d\n", op, m.operations[op].requests)
+	}
+
+	header("permify_client_errors_total", "counter", "Failed calls by error class.")
+	for _, op := range ops {
+		classes := make([]string, 0, len(m.operations[op].errors))
+		for class := range m.operations[op].errors {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(cw, "permify_client_errors_total{operation=%q,class=%q} %d\n", op, class, m.operations[op].errors[class])
+		}
+	}
+
+	header("permify_client_retries_total", "counter", "Attempts repeated by the retry policy.")
+	for _, op := range ops {
+		fmt.Fprintf(cw, "permify_client_retries_total{operation=%q} %d\n", op, m.operations[op].retries)
+	}
+
+	header("permify_client_rate_limit_wait_seconds_total", "counter", "Time spent waiting for the client rate limiter.")
+	for _, op := range ops {
+		fmt.Fprintf(cw, "permify_client_rate_limit_wait_seconds_total{operation=%q} %s\n", op, formatFloat(m.operations[op].waitSeconds))
+	}
+
+	header("permify_client_request_duration_seconds", "histogram", "Latency of calls including rate limiting and retries.")
+	for _, op := range ops {
+		o := m.operations[op]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(cw, "permify_client_request_duration_seconds_bucket{operation=%q,le=%q} %d\n", op, formatFloat(bound), o.latency[i])
+		}
+		fmt.Fprintf(cw, "permify_client_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", op, o.latencyN)
+		fmt.Fprintf(cw, "permify_client_request_duration_seconds_sum{operation=%q} %s\n", op, formatFloat(o.latencySum))
+		fmt.Fprintf(cw, "permify_client_request_duration_seconds_count{operation=%q} %d\n", op, o.latencyN)
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter counts written bytes and keeps the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
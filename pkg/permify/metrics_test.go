@@ -0,0 +1,102 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+
+	httpClient, _ := newSequenceClient(
+		mockStep{status: http.StatusServiceUnavailable},
+		mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`},
+		mockStep{status: http.StatusBadRequest, body: `{"code":3,"message":"bad"}`},
+		mockStep{status: http.StatusTooManyRequests},
+	)
+	metrics := permify.NewMetrics(0.5, 0.1)
+	config := permify.NewDefaultConfig()
+	config.Client = httpClient
+	config.RateLimit = 1000
+	config.Retry = fastRetryPolicy()
+	config.Retry.RetryableStatusCodes = []int{http.StatusServiceUnavailable}
+	config.Metrics = metrics
+	client := permify.NewClient(config)
+
+	// one retried success, one client error and one throttled check
+	for i := 0; i < 3; i++ {
+		_, _ = client.CheckPermission(ctx, subject, entity, "view")
+	}
+	_, err := client.LookupRelationship(ctx, &permify.LookupRelationshipRequest{
+		EntityType: "doc",
+		Permission: "read",
+		Subject:    subject,
+	})
+	assert.Error(t, err)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, permify.MetricsContentType, recorder.Header().Get("Content-Type"))
+
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE permify_client_requests_total counter",
+		`permify_client_requests_total{operation="check"} 3`,
+		`permify_client_requests_total{operation="lookup"} 1`,
+		`permify_client_errors_total{operation="check",class="client"} 1`,
+		`permify_client_errors_total{operation="check",class="throttled"} 1`,
+		`permify_client_errors_total{operation="lookup",class="throttled"} 1`,
+		`permify_client_retries_total{operation="check"} 1`,
+		`permify_client_retries_total{operation="lookup"} 0`,
+		"# TYPE permify_client_request_duration_seconds histogram",
+		`permify_client_request_duration_seconds_bucket{operation="check",le="0.1"} 3`,
+		`permify_client_request_duration_seconds_bucket{operation="check",le="0.5"} 3`,
+		`permify_client_request_duration_seconds_bucket{operation="check",le="+Inf"} 3`,
+		`permify_client_request_duration_seconds_count{operation="check"} 3`,
+		`permify_client_rate_limit_wait_seconds_total{operation="check"}`,
+	} {
+		assert.Contains(t, body, line)
+	}
+	// buckets are rendered in ascending order whatever order they were given in
+	assert.Less(t, strings.Index(body, `le="0.1"`), strings.Index(body, `le="0.5"`))
+}
+
+func TestMetricsRateLimitClasses(t *testing.T) {
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+
+	httpClient, _ := newSequenceClient(mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED"}`})
+	metrics := permify.NewMetrics()
+	config := permify.NewDefaultConfig()
+	config.Client = httpClient
+	config.RateLimit = 1
+	config.Metrics = metrics
+	client := permify.NewClient(config)
+
+	// the first check spends the only token
+	_, err := client.CheckPermission(context.Background(), subject, entity, "view")
+	assert.NoError(t, err)
+	_, err = client.CheckPermission(permify.WithoutWaiting(context.Background()), subject, entity, "view")
+	assert.ErrorIs(t, err, permify.ErrRateLimitWouldBlock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.CheckPermission(ctx, subject, entity, "view")
+	assert.ErrorIs(t, err, permify.ErrRateLimitDeadline)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, `permify_client_errors_total{operation="check",class="would_block"} 1`)
+	assert.Contains(t, body, `permify_client_errors_total{operation="check",class="rate_limit_deadline"} 1`)
+	assert.NotContains(t, body, `class="rate_limited"`)
+}
@@ -0,0 +1,135 @@
+package permify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateIDs(t *testing.T) {
+	ctx := context.Background()
+	newConfig := func(steps ...mockStep) (*permify.Config, *SequenceRoundTripper) {
+		httpClient, rt := newSequenceClient(steps...)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1000
+		return config, rt
+	}
+
+	t.Run("Reencodes", func(t *testing.T) {
+		config, rt := newConfig(
+			mockStep{status: http.StatusOK, body: `{"tuples": [
+				{"entity": {"type": "team", "id": "org_1"}, "relation": "member", "subject": {"type": "user", "id": "org_alice"}},
+				{"entity": {"type": "team", "id": "org_1"}, "relation": "member", "subject": {"type": "user", "id": "bob-smith"}}
+			]}`},
+			mockStep{status: http.StatusOK, body: `{"snap_token": "snap1"}`},
+			mockStep{status: http.StatusOK, body: `{"snap_token": "snap2"}`},
+		)
+
+		migrated, err := permify.MigrateIDs(ctx, config, permify.SeparatorIDCodec{}, permify.EscapeIDCodec{}, "team")
+		require.NoError(t, err)
+		assert.Equal(t, 1, migrated)
+		require.Len(t, rt.requests, 3)
+		assert.Equal(t, "/v1/tenants/t1/data/relationships/read", rt.requests[0].URL.Path)
+
+		// sent IDs are decoded as plain strings, as Permify stores them
+		type wireEntity struct {
+			Type string `json:"type"`
+			Id   string `json:"id"`
+		}
+		var write struct {
+			Tuples []struct {
+				Entity  wireEntity `json:"entity"`
+				Subject wireEntity `json:"subject"`
+			} `json:"tuples"`
+		}
+		decodeSent(t, rt.requests[1], &write)
+		require.Len(t, write.Tuples, 1)
+		assert.Equal(t, wireEntity{Type: "team", Id: "org_1"}, write.Tuples[0].Entity)
+		assert.Equal(t, wireEntity{Type: "user", Id: "bob--smith"}, write.Tuples[0].Subject)
+
+		type wireIDSet struct {
+			Ids []string `json:"ids"`
+		}
+		var remove struct {
+			Filter struct {
+				Entity   wireIDSet `json:"entity"`
+				Relation string    `json:"relation"`
+				Subject  wireIDSet `json:"subject"`
+			} `json:"filter"`
+		}
+		decodeSent(t, rt.requests[2], &remove)
+		assert.Equal(t, []string{"org_1"}, remove.Filter.Entity.Ids)
+		assert.Equal(t, "member", remove.Filter.Relation)
+		assert.Equal(t, []string{"bob-smith"}, remove.Filter.Subject.Ids)
+
+		// the caller's config is left alone
+		assert.Nil(t, config.IDCodec)
+	})
+
+	t.Run("Subject Sets", func(t *testing.T) {
+		config, rt := newConfig(
+			mockStep{status: http.StatusOK, body: `{"tuples": [
+				{"entity": {"type": "project", "id": "org_1"}, "relation": "team", "subject": {"type": "team", "id": "eng-1", "relation": "member"}}
+			]}`},
+			mockStep{status: http.StatusOK, body: `{"snap_token": "snap1"}`},
+			mockStep{status: http.StatusOK, body: `{"snap_token": "snap2"}`},
+		)
+
+		migrated, err := permify.MigrateIDs(ctx, config, permify.SeparatorIDCodec{}, permify.EscapeIDCodec{}, "project")
+		require.NoError(t, err)
+		assert.Equal(t, 1, migrated)
+		require.Len(t, rt.requests, 3)
+
+		type wireSubject struct {
+			Id       string   `json:"id"`
+			Ids      []string `json:"ids"`
+			Relation string   `json:"relation"`
+		}
+		var write struct {
+			Tuples []struct {
+				Subject wireSubject `json:"subject"`
+			} `json:"tuples"`
+		}
+		decodeSent(t, rt.requests[1], &write)
+		require.Len(t, write.Tuples, 1)
+		assert.Equal(t, "eng--1", write.Tuples[0].Subject.Id)
+		assert.Equal(t, "member", write.Tuples[0].Subject.Relation)
+
+		// only the subject set is deleted, not the tuples of other relations
+		var remove struct {
+			Filter struct {
+				Subject wireSubject `json:"subject"`
+			} `json:"filter"`
+		}
+		decodeSent(t, rt.requests[2], &remove)
+		assert.Equal(t, []string{"eng-1"}, remove.Filter.Subject.Ids)
+		assert.Equal(t, "member", remove.Filter.Subject.Relation)
+	})
+
+	t.Run("Nothing To Migrate", func(t *testing.T) {
+		config, rt := newConfig(mockStep{status: http.StatusOK, body: `{"tuples": [
+			{"entity": {"type": "team", "id": "org_1"}, "relation": "member", "subject": {"type": "user", "id": "org_alice"}}
+		]}`})
+
+		migrated, err := permify.MigrateIDs(ctx, config, permify.SeparatorIDCodec{}, permify.EscapeIDCodec{}, "team")
+		require.NoError(t, err)
+		assert.Zero(t, migrated)
+		assert.Equal(t, 1, rt.Calls())
+	})
+
+	t.Run("Rejects IDs That Do Not Round Trip", func(t *testing.T) {
+		config, rt := newConfig(mockStep{status: http.StatusOK, body: `{"tuples": [
+			{"entity": {"type": "team", "id": "org_1"}, "relation": "member", "subject": {"type": "user", "id": "a-_b"}}
+		]}`})
+
+		_, err := permify.MigrateIDs(ctx, config, permify.EscapeIDCodec{}, permify.SeparatorIDCodec{}, "team")
+		assert.True(t, errors.Is(err, permify.ErrInvalidID))
+		assert.Equal(t, 1, rt.Calls())
+	})
+}
@@ -8,8 +8,9 @@ type Entity struct {
 
 // Subject is an alias for Entity, representing an entity that is the target or receiver of a relationship.
 type Subject struct {
-	Type string `json:"type" validate:"required"`
-	Id   string `json:"id" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Id       string `json:"id" validate:"required"`
+	Relation string `json:"relation,omitempty"` // Set for subject sets, e.g. member of team:eng#member
 }
 
 // Relationship defines a relation between two entities.
@@ -26,6 +27,12 @@ type RelationshipFilter struct {
 	Subject  SubjectIDSet `json:"subject"`
 }
 
+// AttributeFilter selects attributes by entity and attribute name.
+type AttributeFilter struct {
+	Entity     EntityIDSet `json:"entity"`
+	Attributes []string    `json:"attributes,omitempty"` // Attribute names, all of them when empty
+}
+
 // EntityIDSet represents a set of entity IDs.
 type EntityIDSet struct {
 	Type string   `json:"type"`
@@ -59,6 +66,13 @@ type Metadata struct {
 	Depth  int    `json:"depth,omitempty"`          // Used when checking permissions to limit the depth of the graph search
 }
 
+// Context carries request scoped data a permission is evaluated with.
+type Context struct {
+	Tuples     []*Relationship        `json:"tuples,omitempty"`     // Relationships that only exist for this request
+	Attributes []*Attribute           `json:"attributes,omitempty"` // Attributes that only exist for this request
+	Data       map[string]interface{} `json:"data,omitempty"`       // Free form data available to the schema's rules
+}
+
 type PermissionCheckResponseMetadata struct {
 	CheckCount int `json:"check_count"`
 }
@@ -73,27 +87,41 @@ type Tenant struct {
 // Internal only models
 //
 
-type findRelationshipsResponse struct {
+type bulkCheckRequest struct {
+	Metadata Metadata         `json:"metadata"`
+	Items    []*bulkCheckItem `json:"items"`
+	Context  *Context         `json:"context,omitempty"`
+}
+
+type bulkCheckItem struct {
+	Entity     *Entity  `json:"entity"`
+	Permission string   `json:"permission"`
+	Subject    *Subject `json:"subject"`
+}
+
+type bulkCheckResponse struct {
 	*ErrorResponse `json:",inline"`
-	Tree           relationshipsTree `json:"tree"`
+	Results        []PermissionCheckResponse `json:"results"`
 }
 
-type relationshipsTree struct {
-	Entity     Entity       `json:"entity"`
-	Permission string       `json:"permission"`
-	Children   discoverySet `json:"leaf"`
+type subjectPermissionMetadata struct {
+	Metadata
+	OnlyPermission bool `json:"only_permission,omitempty"`
 }
 
-type discoverySet struct {
-	Subjects subjectSet `json:"subjects"`
+type subjectPermissionRequest struct {
+	Metadata subjectPermissionMetadata `json:"metadata"`
+	Entity   *Entity                   `json:"entity"`
+	Subject  *Subject                  `json:"subject"`
+	Context  *Context                  `json:"context,omitempty"`
 }
 
-type subjectSet struct {
-	Subjects []leaf `json:"subjects"`
+type subjectPermissionResponse struct {
+	*ErrorResponse `json:",inline"`
+	Results        map[string]string `json:"results"`
 }
 
-type leaf struct {
-	Type     string `json:"type"`
-	Id       string `json:"id"`
-	Relation string `json:"relation"`
+type findRelationshipsResponse struct {
+	*ErrorResponse `json:",inline"`
+	Tree           ExpandTree `json:"tree"`
 }
@@ -0,0 +1,92 @@
+package permify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SubjectPermissions returns which permissions and relations of the entity
+// the subject holds, e.g. to decide which buttons of a page to enable. When
+// the server does not know the subject permission endpoint the request's
+// Permissions are checked one by one with CheckMany instead.
+func (c *client) SubjectPermissions(ctx context.Context, request *SubjectPermissionRequest) (*SubjectPermissionResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if request.Entity == nil || request.Entity.Id == "" || request.Entity.Type == "" {
+		return nil, fmt.Errorf("entity is invalid")
+	}
+	if request.Subject == nil || request.Subject.Id == "" || request.Subject.Type == "" {
+		return nil, fmt.Errorf("subject is invalid")
+	}
+	if err := validateContext(request.Context); err != nil {
+		return nil, err
+	}
+	metadata := c.metadata(ctx, request.Metadata)
+
+	if c.subjectPermissionUnsupported.Load() && len(request.Permissions) > 0 {
+		return c.fanOutPermissions(ctx, request, metadata)
+	}
+
+	resolved := &subjectPermissionRequest{
+		Metadata: subjectPermissionMetadata{Metadata: metadata, OnlyPermission: request.OnlyPermissions},
+		Entity:   request.Entity,
+		Subject:  request.Subject,
+		Context:  request.Context,
+	}
+
+	url := c.constructURL(SubjectPermissionAPIPath)
+	body, err := c.sendRequest(ctx, OperationSubjectPermission, http.MethodPost, url, resolved)
+	if err != nil {
+		// servers without the endpoint are remembered, other errors are not
+		if isUnimplemented(err) && len(request.Permissions) > 0 {
+			c.subjectPermissionUnsupported.Store(true)
+			return c.fanOutPermissions(ctx, request, metadata)
+		}
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response subjectPermissionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse subject permission response: %w", err)
+	}
+
+	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
+		return nil, responseError(OperationSubjectPermission, url, response.ErrorResponse)
+	}
+
+	permissions := make(map[string]bool, len(response.Results))
+	for name, result := range response.Results {
+		permissions[name] = result == CheckResponseAllowed
+	}
+	return &SubjectPermissionResponse{Permissions: permissions}, nil
+}
+
+// fanOutPermissions answers a SubjectPermissionRequest with one check per
+// name of its Permissions. It fails if any of the checks does.
+func (c *client) fanOutPermissions(ctx context.Context, request *SubjectPermissionRequest, metadata Metadata) (*SubjectPermissionResponse, error) {
+	items := make([]CheckItem, len(request.Permissions))
+	for i, permission := range request.Permissions {
+		items[i] = CheckItem{Entity: request.Entity, Permission: permission, Subject: request.Subject}
+	}
+	result, err := c.CheckMany(ctx, items, &CheckManyOptions{
+		CheckOptions: []CheckOption{
+			CheckConsistency(Consistency{SnapToken: metadata.Snap, SchemaVersion: metadata.Schema, Depth: metadata.Depth}),
+			func(r *PermissionCheckRequest) { r.Context = request.Context },
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(result.Results))
+	for _, r := range result.Results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrUnableToListPermissions, r.Item.Permission, r.Err)
+		}
+		permissions[r.Item.Permission] = r.Decision.Allowed
+	}
+	return &SubjectPermissionResponse{Permissions: permissions, FannedOut: true}, nil
+}
@@ -0,0 +1,121 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectPermissions(t *testing.T) {
+	ctx := context.Background()
+	team := &permify.Entity{Type: "team", Id: "org.1"}
+	alice := &permify.Subject{Type: "user", Id: "org.alice"}
+
+	newPermissionsClient := func(transport http.RoundTripper) permify.RelationshipClient {
+		config := permify.NewDefaultConfig()
+		config.Client = &http.Client{Transport: transport}
+		config.RateLimit = 1000
+		return permify.NewClient(config)
+	}
+
+	t.Run("Endpoint", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(mockStep{status: http.StatusOK, body: `{"results": {
+			"edit": "CHECK_RESULT_ALLOWED",
+			"delete": "CHECK_RESULT_DENIED",
+			"invite": "CHECK_RESULT_ALLOWED"
+		}}`})
+		client := newPermissionsClient(httpClient.Transport)
+
+		response, err := client.SubjectPermissions(permify.WithSnapToken(ctx, "snap1"), &permify.SubjectPermissionRequest{
+			Entity:          team,
+			Subject:         alice,
+			OnlyPermissions: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"edit": true, "delete": false, "invite": true}, response.Permissions)
+		assert.False(t, response.FannedOut)
+
+		assert.Equal(t, "/v1/tenants/t1/permissions/subject-permission", rt.requests[0].URL.Path)
+		var sent struct {
+			Metadata struct {
+				Snap           string `json:"snap_token"`
+				Depth          int    `json:"depth"`
+				OnlyPermission bool   `json:"only_permission"`
+			} `json:"metadata"`
+			Entity  map[string]string `json:"entity"`
+			Subject map[string]string `json:"subject"`
+		}
+		decodeSent(t, rt.requests[0], &sent)
+		assert.Equal(t, "snap1", sent.Metadata.Snap)
+		assert.Equal(t, permify.DefaultDepth, sent.Metadata.Depth)
+		assert.True(t, sent.Metadata.OnlyPermission)
+		assert.Equal(t, "org_1", sent.Entity["id"])
+		assert.Equal(t, "org_alice", sent.Subject["id"])
+		assert.Equal(t, "org.1", team.Id)
+	})
+
+	t.Run("Fan Out Without Endpoint", func(t *testing.T) {
+		server := &PermissionServer{allowed: map[string]bool{
+			"team:org_1#edit@user:org_alice":   true,
+			"team:org_1#invite@user:org_alice": true,
+		}}
+		client := newPermissionsClient(server)
+		request := &permify.SubjectPermissionRequest{
+			Entity:      team,
+			Subject:     alice,
+			Permissions: []string{"edit", "delete", "invite", "remove_user"},
+		}
+
+		for i := 0; i < 2; i++ {
+			response, err := client.SubjectPermissions(ctx, request)
+			require.NoError(t, err)
+			assert.True(t, response.FannedOut)
+			assert.Equal(t, map[string]bool{"edit": true, "delete": false, "invite": true, "remove_user": false}, response.Permissions)
+		}
+		// the missing endpoint is remembered
+		assert.Equal(t, 8, server.checks)
+		assert.Equal(t, 1, server.subjectChecks)
+	})
+
+	t.Run("Not Found Does Not Force Fan Out", func(t *testing.T) {
+		server := &PermissionServer{notFound: 1}
+		client := newPermissionsClient(server)
+		request := &permify.SubjectPermissionRequest{Entity: team, Subject: alice, Permissions: []string{"edit"}}
+
+		_, err := client.SubjectPermissions(ctx, request)
+		assert.ErrorIs(t, err, permify.ErrUnableToListPermissions)
+		assert.Equal(t, 0, server.checks)
+
+		response, err := client.SubjectPermissions(ctx, request)
+		require.NoError(t, err)
+		assert.True(t, response.FannedOut)
+		assert.Equal(t, 2, server.subjectChecks)
+	})
+
+	t.Run("No Permissions To Fan Out", func(t *testing.T) {
+		client := newPermissionsClient(&PermissionServer{})
+
+		_, err := client.SubjectPermissions(ctx, &permify.SubjectPermissionRequest{Entity: team, Subject: alice})
+		assert.ErrorIs(t, err, permify.ErrUnableToListPermissions)
+	})
+
+	t.Run("Invalid Request", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(mockStep{status: http.StatusOK, body: `{}`})
+		client := newPermissionsClient(httpClient.Transport)
+
+		for _, request := range []*permify.SubjectPermissionRequest{
+			nil,
+			{Subject: alice},
+			{Entity: team},
+			{Entity: team, Subject: alice, Context: &permify.Context{Tuples: []*permify.Relationship{nil}}},
+		} {
+			_, err := client.SubjectPermissions(ctx, request)
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 0, rt.Calls())
+	})
+}
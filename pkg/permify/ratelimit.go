@@ -0,0 +1,315 @@
+package permify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultBurst = 1
+
+	DefaultAdaptiveMinRate        = 1
+	DefaultAdaptiveIncrease       = 1
+	DefaultAdaptiveDecreaseFactor = 0.5
+	DefaultAdaptiveCooldown       = time.Second
+)
+
+// BucketLimit is the budget of a single rate limiter bucket.
+type BucketLimit struct {
+	Rate  float64 // Requests per second
+	Burst int     // Maximum burst, defaults to DefaultBurst
+}
+
+// RateLimitWait describes how long a call waited on one rate limiter bucket.
+type RateLimitWait struct {
+	Operation Operation
+	Bucket    Bucket
+	Wait      time.Duration
+}
+
+// BucketWaitStats aggregates the waits of all calls on one bucket.
+type BucketWaitStats struct {
+	Waits uint64        // Number of calls that acquired a token
+	Total time.Duration // Time spent waiting for tokens
+}
+
+// bucket is a named rate limiter that keeps track of the time spent waiting on it.
+type bucket struct {
+	name    Bucket
+	limiter *rate.Limiter
+	waits   atomic.Uint64
+	waited  atomic.Int64
+}
+
+func newBucket(name Bucket, r float64, burst int) *bucket {
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	return &bucket{name: name, limiter: rate.NewLimiter(rate.Limit(r), burst)}
+}
+
+func (b *bucket) record(wait time.Duration) {
+	b.waits.Add(1)
+	b.waited.Add(int64(wait))
+}
+
+func (b *bucket) stats() BucketWaitStats {
+	return BucketWaitStats{Waits: b.waits.Load(), Total: time.Duration(b.waited.Load())}
+}
+
+// bucketsFor returns the limiters an operation has to pass, in order. An
+// operation with its own bucket skips the global limiter unless the global
+// ceiling is enabled.
+func (c *client) bucketsFor(op Operation) []*bucket {
+	b, ok := c.buckets[op.Bucket()]
+	if !ok {
+		return []*bucket{c.global}
+	}
+	if c.config.GlobalCeiling {
+		return []*bucket{b, c.global}
+	}
+	return []*bucket{b}
+}
+
+type noWaitKey struct{}
+
+// WithoutWaiting returns a context for calls that should fail fast with
+// ErrRateLimitWouldBlock rather than queue for a rate limiter token.
+func WithoutWaiting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noWaitKey{}, true)
+}
+
+// waitingDisabled reports whether the caller opted out of queuing.
+func (c *client) waitingDisabled(ctx context.Context) bool {
+	noWait, _ := ctx.Value(noWaitKey{}).(bool)
+	return noWait || c.config.NonBlocking
+}
+
+// wait blocks until the operation may be sent. Tokens are reserved on all
+// buckets up front so a call that cannot proceed gives all of them back.
+// It returns ErrRateLimitCancelled when the context ends while waiting,
+// ErrRateLimitDeadline when the context's deadline passes before a token is
+// available, ErrRateLimitWouldBlock when the caller opted out of waiting and
+// ErrRateLimitExceeded when the limiter can never grant a token.
+func (c *client) wait(ctx context.Context, op Operation) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrRateLimitCancelled, err)
+	}
+
+	buckets := c.bucketsFor(op)
+	reservations := make([]*rate.Reservation, 0, len(buckets))
+	release := func() {
+		for _, r := range reservations {
+			r.Cancel()
+		}
+	}
+
+	now := time.Now()
+	delays := make([]time.Duration, len(buckets))
+	var delay time.Duration
+	for i, b := range buckets {
+		r := b.limiter.ReserveN(now, 1)
+		if !r.OK() {
+			release()
+			return ErrRateLimitExceeded
+		}
+		reservations = append(reservations, r)
+		delays[i] = r.DelayFrom(now)
+		if delays[i] > delay {
+			delay = delays[i]
+		}
+	}
+
+	if delay > 0 {
+		if c.waitingDisabled(ctx) {
+			release()
+			return ErrRateLimitWouldBlock
+		}
+		if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+			release()
+			return ErrRateLimitDeadline
+		}
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			release()
+			return fmt.Errorf("%w: %w", ErrRateLimitCancelled, ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	c.config.Metrics.observeWait(op, delay)
+	for i, b := range buckets {
+		b.record(delays[i])
+		if c.config.OnRateLimitWait != nil {
+			c.config.OnRateLimitWait(RateLimitWait{Operation: op, Bucket: b.name, Wait: delays[i]})
+		}
+	}
+	return nil
+}
+
+// AdaptiveRateLimit configures an AIMD (additive increase, multiplicative
+// decrease) rate limiter. The rate grows by Increase requests per second for
+// every second of successful calls and is multiplied by DecreaseFactor when
+// the server throttles (429), fails (5xx) or times out.
+type AdaptiveRateLimit struct {
+	MinRate        float64       // Floor for the rate in requests per second
+	MaxRate        float64       // Ceiling for the rate in requests per second
+	Increase       float64       // Additive increase in requests per second
+	DecreaseFactor float64       // Multiplicative decrease, between 0 and 1
+	Cooldown       time.Duration // Minimum time between two decreases
+}
+
+// NewDefaultAdaptiveRateLimit returns an adaptive configuration that can grow
+// up to maxRate requests per second.
+func NewDefaultAdaptiveRateLimit(maxRate float64) *AdaptiveRateLimit {
+	return &AdaptiveRateLimit{
+		MinRate:        DefaultAdaptiveMinRate,
+		MaxRate:        maxRate,
+		Increase:       DefaultAdaptiveIncrease,
+		DecreaseFactor: DefaultAdaptiveDecreaseFactor,
+		Cooldown:       DefaultAdaptiveCooldown,
+	}
+}
+
+// RateLimitSnapshot describes the state of the client's rate limiter.
+type RateLimitSnapshot struct {
+	Adaptive  bool    // Whether the rate adapts to server feedback
+	Rate      float64 // Current effective rate in requests per second
+	MinRate   float64 // Floor of an adaptive limiter
+	MaxRate   float64 // Ceiling of an adaptive limiter
+	Increases uint64  // Number of successful calls that raised the rate
+	Decreases uint64  // Number of times the rate was cut
+
+	Waits map[Bucket]BucketWaitStats // Time spent waiting, per bucket
+}
+
+// RateLimitReporter is implemented by clients that can report their rate limiter state.
+type RateLimitReporter interface {
+	// RateLimitSnapshot returns the current state of the rate limiter. For an
+	// adaptive limiter the rate is a measurement of the server's capacity.
+	RateLimitSnapshot() RateLimitSnapshot
+}
+
+// adaptiveLimiter adjusts the rate of a rate.Limiter based on call outcomes.
+// It drives the global bucket only, per operation buckets stay fixed.
+type adaptiveLimiter struct {
+	limiter *rate.Limiter
+	config  AdaptiveRateLimit
+
+	mu           sync.Mutex
+	current      float64
+	lastDecrease time.Time
+	increases    uint64
+	decreases    uint64
+}
+
+// newAdaptiveLimiter drives limiter starting at its current rate.
+func newAdaptiveLimiter(limiter *rate.Limiter, config AdaptiveRateLimit) *adaptiveLimiter {
+	if config.MinRate <= 0 {
+		config.MinRate = DefaultAdaptiveMinRate
+	}
+	if config.MaxRate < config.MinRate {
+		config.MaxRate = math.Max(float64(limiter.Limit()), config.MinRate)
+	}
+	if config.Increase <= 0 {
+		config.Increase = DefaultAdaptiveIncrease
+	}
+	if config.DecreaseFactor <= 0 || config.DecreaseFactor >= 1 {
+		config.DecreaseFactor = DefaultAdaptiveDecreaseFactor
+	}
+	if config.Cooldown < 0 {
+		config.Cooldown = 0
+	}
+
+	a := &adaptiveLimiter{limiter: limiter, config: config}
+	a.current = a.clamp(float64(limiter.Limit()))
+	limiter.SetLimit(rate.Limit(a.current))
+	return a
+}
+
+func (a *adaptiveLimiter) clamp(r float64) float64 {
+	return math.Min(math.Max(r, a.config.MinRate), a.config.MaxRate)
+}
+
+// observe feeds the outcome of one attempt into the limiter. It is a no-op on
+// a nil limiter so callers need not check whether adaptive mode is enabled.
+func (a *adaptiveLimiter) observe(status int, err error) {
+	if a == nil {
+		return
+	}
+
+	throttled := isThrottled(status, err)
+	success := err == nil && status >= 200 && status < 300
+	if !throttled && !success {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if throttled {
+		now := time.Now()
+		if now.Sub(a.lastDecrease) < a.config.Cooldown {
+			return
+		}
+		a.lastDecrease = now
+		a.current = a.clamp(a.current * a.config.DecreaseFactor)
+		a.decreases++
+	} else {
+		// spread the increase over a second worth of calls at the current rate
+		a.current = a.clamp(a.current + a.config.Increase/a.current)
+		a.increases++
+	}
+	a.limiter.SetLimit(rate.Limit(a.current))
+}
+
+func (a *adaptiveLimiter) snapshot() RateLimitSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return RateLimitSnapshot{
+		Adaptive:  true,
+		Rate:      a.current,
+		MinRate:   a.config.MinRate,
+		MaxRate:   a.config.MaxRate,
+		Increases: a.increases,
+		Decreases: a.decreases,
+	}
+}
+
+// isThrottled reports whether an attempt indicates the server is overloaded.
+func isThrottled(status int, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// RateLimitSnapshot returns the current state of the client's rate limiter.
+func (c *client) RateLimitSnapshot() RateLimitSnapshot {
+	var snapshot RateLimitSnapshot
+	if c.adaptive != nil {
+		snapshot = c.adaptive.snapshot()
+	} else {
+		limit := float64(c.global.limiter.Limit())
+		snapshot = RateLimitSnapshot{Rate: limit, MinRate: limit, MaxRate: limit}
+	}
+
+	snapshot.Waits = map[Bucket]BucketWaitStats{BucketGlobal: c.global.stats()}
+	for name, b := range c.buckets {
+		snapshot.Waits[name] = b.stats()
+	}
+	return snapshot
+}
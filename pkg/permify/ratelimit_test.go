@@ -0,0 +1,225 @@
+package permify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveRateLimit(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+
+	newAdaptiveClient := func(steps ...mockStep) permify.RelationshipClient {
+		httpClient, _ := newSequenceClient(steps...)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 100
+		config.AdaptiveRateLimit = &permify.AdaptiveRateLimit{
+			MinRate:        20,
+			MaxRate:        101,
+			Increase:       10,
+			DecreaseFactor: 0.5,
+		}
+		return permify.NewClient(config)
+	}
+
+	t.Run("Fixed Limiter Snapshot", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient(`{"can": "CHECK_RESULT_ALLOWED"}`, http.StatusOK)
+		client := permify.NewClient(config)
+
+		snapshot := client.(permify.RateLimitReporter).RateLimitSnapshot()
+		assert.False(t, snapshot.Adaptive)
+		assert.Equal(t, float64(permify.DefaultRateLimit), snapshot.Rate)
+	})
+
+	t.Run("Decreases Multiplicatively Down To Floor", func(t *testing.T) {
+		client := newAdaptiveClient(mockStep{status: http.StatusTooManyRequests})
+		reporter := client.(permify.RateLimitReporter)
+
+		expected := []float64{50, 25, 20, 20}
+		for _, rate := range expected {
+			_, err := client.CheckPermission(ctx, subject, entity, "view")
+			assert.Error(t, err)
+			assert.Equal(t, rate, reporter.RateLimitSnapshot().Rate)
+		}
+		assert.Equal(t, uint64(4), reporter.RateLimitSnapshot().Decreases)
+	})
+
+	t.Run("Increases Additively Up To Ceiling", func(t *testing.T) {
+		client := newAdaptiveClient(mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED"}`})
+		reporter := client.(permify.RateLimitReporter)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.InDelta(t, 100.1, reporter.RateLimitSnapshot().Rate, 0.001)
+
+		for i := 0; i < 20; i++ {
+			_, err := client.CheckPermission(ctx, subject, entity, "view")
+			assert.NoError(t, err)
+		}
+		snapshot := reporter.RateLimitSnapshot()
+		assert.True(t, snapshot.Adaptive)
+		assert.Equal(t, float64(101), snapshot.Rate)
+		assert.Equal(t, uint64(21), snapshot.Increases)
+	})
+
+	t.Run("Client Errors Leave Rate Unchanged", func(t *testing.T) {
+		client := newAdaptiveClient(mockStep{status: http.StatusBadRequest, body: `{"code":3,"message":"bad"}`})
+		reporter := client.(permify.RateLimitReporter)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.Error(t, err)
+		assert.Equal(t, float64(100), reporter.RateLimitSnapshot().Rate)
+	})
+}
+
+func TestBucketLimits(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+	write := &permify.AddRelationshipRequest{
+		Relationships: []*permify.Relationship{
+			{
+				Entity:   &permify.Entity{Type: "doc", Id: "doc1"},
+				Relation: "owner",
+				Subject:  &permify.Subject{Type: "user", Id: "user1"},
+			},
+		},
+	}
+
+	newBucketClient := func(globalCeiling bool, waits *[]permify.RateLimitWait) permify.RelationshipClient {
+		httpClient, _ := newSequenceClient(mockStep{status: http.StatusOK, body: `{"snap_token":"snap","can":"CHECK_RESULT_ALLOWED"}`})
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1000
+		config.BucketLimits = map[permify.Bucket]permify.BucketLimit{
+			permify.BucketWrite: {Rate: 0.1, Burst: 2},
+		}
+		config.GlobalCeiling = globalCeiling
+		config.OnRateLimitWait = func(wait permify.RateLimitWait) {
+			*waits = append(*waits, wait)
+		}
+		return permify.NewClient(config)
+	}
+
+	t.Run("Writes Do Not Starve Checks", func(t *testing.T) {
+		var waits []permify.RateLimitWait
+		client := newBucketClient(false, &waits)
+
+		// the burst allows two writes, the third one has to queue
+		for i := 0; i < 2; i++ {
+			_, err := client.AddRelationship(ctx, write)
+			assert.NoError(t, err)
+		}
+		shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := client.AddRelationship(shortCtx, write)
+		assert.ErrorIs(t, err, permify.ErrRateLimitDeadline)
+
+		// checks draw from the global limiter and are unaffected
+		allowed, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+
+		assert.Len(t, waits, 3)
+		assert.Equal(t, permify.BucketWrite, waits[0].Bucket)
+		assert.Equal(t, permify.OperationWrite, waits[0].Operation)
+		assert.Equal(t, permify.BucketGlobal, waits[2].Bucket)
+		assert.Equal(t, permify.OperationCheck, waits[2].Operation)
+
+		stats := client.(permify.RateLimitReporter).RateLimitSnapshot().Waits
+		assert.Equal(t, uint64(2), stats[permify.BucketWrite].Waits)
+		assert.Equal(t, uint64(1), stats[permify.BucketGlobal].Waits)
+	})
+
+	t.Run("Global Ceiling Applies On Top", func(t *testing.T) {
+		var waits []permify.RateLimitWait
+		client := newBucketClient(true, &waits)
+
+		_, err := client.AddRelationship(ctx, write)
+		assert.NoError(t, err)
+		assert.Len(t, waits, 2)
+		assert.Equal(t, permify.BucketWrite, waits[0].Bucket)
+		assert.Equal(t, permify.BucketGlobal, waits[1].Bucket)
+	})
+}
+
+func TestRateLimitWaitErrors(t *testing.T) {
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+
+	// a slow limiter whose only token is spent by the first call
+	newSlowClient := func(steps ...mockStep) permify.RelationshipClient {
+		if len(steps) == 0 {
+			steps = []mockStep{{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED"}`}}
+		}
+		httpClient, _ := newSequenceClient(steps...)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.RateLimit = 1
+		client := permify.NewClient(config)
+		_, err := client.CheckPermission(context.Background(), subject, entity, "view")
+		assert.NoError(t, err)
+		return client
+	}
+
+	t.Run("Cancelled Context", func(t *testing.T) {
+		client := newSlowClient()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrRateLimitCancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, permify.ErrRateLimitExceeded)
+	})
+
+	t.Run("Cancelled While Waiting", func(t *testing.T) {
+		client := newSlowClient()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrRateLimitCancelled)
+	})
+
+	t.Run("Deadline Before Token", func(t *testing.T) {
+		client := newSlowClient()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrRateLimitDeadline)
+		assert.NotErrorIs(t, err, permify.ErrRateLimitExceeded)
+		assert.NotErrorIs(t, err, permify.ErrRateLimitCancelled)
+	})
+
+	t.Run("Non Blocking Fails Fast", func(t *testing.T) {
+		client := newSlowClient()
+		start := time.Now()
+
+		_, err := client.CheckPermission(permify.WithoutWaiting(context.Background()), subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrRateLimitWouldBlock)
+		assert.NotErrorIs(t, err, permify.ErrRateLimitExceeded)
+		assert.NotErrorIs(t, err, permify.ErrRateLimitDeadline)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("Server Throttling", func(t *testing.T) {
+		config := permify.NewDefaultConfig()
+		config.Client = newMockClient(`{"code":8,"message":"rate limit exceeded"}`, http.StatusTooManyRequests)
+		client := permify.NewClient(config)
+
+		_, err := client.CheckPermission(context.Background(), subject, entity, "view")
+		assert.ErrorIs(t, err, permify.ErrServerThrottled)
+		assert.ErrorIs(t, err, permify.ErrUnableToCheckRelationship)
+		assert.NotErrorIs(t, err, permify.ErrRateLimitExceeded)
+	})
+}
@@ -0,0 +1,138 @@
+package permify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// DefaultReadPageSize is the page size of relationship reads that do not set
+// one, the most Permify returns per page.
+const DefaultReadPageSize = 100
+
+// ReadRelationshipsPage returns a page of the stored relationships matching
+// the filter. Pass the ContinuousToken of a response to get the next page.
+func (c *client) ReadRelationshipsPage(ctx context.Context, request *ReadRelationshipsRequest) (*ReadRelationshipsResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if request.Filter.Entity.Type == "" {
+		return nil, fmt.Errorf("invalid entity in filter")
+	}
+	resolved := *request
+	resolved.Metadata = c.snapshot(ctx, request.Metadata)
+	if resolved.PageSize <= 0 {
+		resolved.PageSize = DefaultReadPageSize
+	}
+
+	url := c.constructURL(RelationshipReadAPIPath)
+	body, err := c.sendRequest(ctx, OperationReadRelationships, http.MethodPost, url, &resolved)
+	if err != nil {
+		return nil, fmt.Errorf("permify request failed: %w", err)
+	}
+
+	var response ReadRelationshipsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse read relationships response: %w", err)
+	}
+
+	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
+		return nil, responseError(OperationReadRelationships, url, response.ErrorResponse)
+	}
+
+	return &response, nil
+}
+
+// ReadRelationships returns an iterator over the stored relationships
+// matching the filter. Pages are fetched as the iterator advances, all of
+// them at the snapshot resolved for the first one.
+func (c *client) ReadRelationships(ctx context.Context, filter RelationshipFilter, pageSize int) *RelationshipIterator {
+	return &RelationshipIterator{
+		ctx:    ctx,
+		client: c,
+		request: ReadRelationshipsRequest{
+			Metadata: c.snapshot(ctx, Metadata{}),
+			Filter:   filter,
+			PageSize: pageSize,
+		},
+	}
+}
+
+// WalkRelationships calls fn for each stored relationship matching the
+// filter. It stops at the first error of fn, which it returns, when ctx ends
+// or when there are no more relationships.
+func (c *client) WalkRelationships(ctx context.Context, filter RelationshipFilter, pageSize int, fn func(*Relationship) error) error {
+	it := c.ReadRelationships(ctx, filter, pageSize)
+	for it.Next() {
+		if err := fn(it.Relationship()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// RelationshipIterator pages through the relationships of a read. Use it as
+//
+//	for it.Next() {
+//		r := it.Relationship()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type RelationshipIterator struct {
+	ctx     context.Context
+	client  *client
+	request ReadRelationshipsRequest
+	page    []*Relationship
+	current *Relationship
+	done    bool // set once the last page was fetched
+	err     error
+}
+
+// Next advances to the next relationship. It returns false when there are no
+// more or the read failed, see Err.
+func (it *RelationshipIterator) Next() bool {
+	it.current = nil
+	if it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	for len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Relationship returns the relationship Next advanced to.
+func (it *RelationshipIterator) Relationship() *Relationship {
+	return it.current
+}
+
+// Err returns the error that stopped the iterator, nil if it ran out of
+// relationships.
+func (it *RelationshipIterator) Err() error {
+	return it.err
+}
+
+// fetch reads the next page.
+func (it *RelationshipIterator) fetch() bool {
+	response, err := it.client.ReadRelationshipsPage(it.ctx, &it.request)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page = response.Relationships
+	// a repeated token would page forever
+	it.done = response.ContinuousToken == "" || response.ContinuousToken == it.request.ContinuousToken
+	it.request.ContinuousToken = response.ContinuousToken
+	return true
+}
@@ -0,0 +1,161 @@
+package permify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRelationships(t *testing.T) {
+	ctx := context.Background()
+	filter := permify.RelationshipFilter{Entity: permify.EntityIDSet{Type: "document", Ids: []string{"org.doc1"}}}
+	pages := []mockStep{
+		{status: http.StatusOK, body: `{"tuples": [
+			{"entity": {"type": "document", "id": "org_doc1"}, "relation": "owner", "subject": {"type": "user", "id": "org_alice"}},
+			{"entity": {"type": "document", "id": "org_doc1"}, "relation": "viewer", "subject": {"type": "user", "id": "bob"}}
+		], "continuous_token": "page2"}`},
+		{status: http.StatusOK, body: `{"tuples": [
+			{"entity": {"type": "document", "id": "org_doc1"}, "relation": "viewer", "subject": {"type": "team", "id": "eng", "relation": "member"}}
+		]}`},
+	}
+
+	sentRequest := func(t *testing.T, req *http.Request) (ids []string, pageSize int, token string) {
+		var payload struct {
+			Filter struct {
+				Entity struct {
+					Ids []string `json:"ids"`
+				} `json:"entity"`
+			} `json:"filter"`
+			PageSize        int    `json:"page_size"`
+			ContinuousToken string `json:"continuous_token"`
+		}
+		decodeSent(t, req, &payload)
+		return payload.Filter.Entity.Ids, payload.PageSize, payload.ContinuousToken
+	}
+
+	t.Run("Page", func(t *testing.T) {
+		client, rt := newTestClient(nil, pages...)
+
+		response, err := client.(permify.DataClient).ReadRelationshipsPage(permify.WithSnapToken(ctx, "snap1"), &permify.ReadRelationshipsRequest{Filter: filter})
+		require.NoError(t, err)
+		assert.Equal(t, "/v1/tenants/t1/data/relationships/read", rt.requests[0].URL.Path)
+		assert.Equal(t, permify.Metadata{Snap: "snap1"}, sentMetadata(t, rt.requests[0]))
+		ids, pageSize, token := sentRequest(t, rt.requests[0])
+		assert.Equal(t, []string{"org_doc1"}, ids)
+		assert.Equal(t, permify.DefaultReadPageSize, pageSize)
+		assert.Empty(t, token)
+
+		require.Len(t, response.Relationships, 2)
+		assert.Equal(t, &permify.Entity{Type: "document", Id: "org.doc1"}, response.Relationships[0].Entity)
+		assert.Equal(t, &permify.Subject{Type: "user", Id: "org.alice"}, response.Relationships[0].Subject)
+		assert.Equal(t, "page2", response.ContinuousToken)
+
+		_, err = client.(permify.DataClient).ReadRelationshipsPage(ctx, &permify.ReadRelationshipsRequest{})
+		assert.Error(t, err)
+		assert.Equal(t, 1, rt.Calls())
+	})
+
+	t.Run("Iterator", func(t *testing.T) {
+		client, rt := newTestClient(nil, pages...)
+
+		it := client.(permify.DataClient).ReadRelationships(ctx, filter, 2)
+		var relations []string
+		var subjects []permify.Subject
+		for it.Next() {
+			relations = append(relations, it.Relationship().Relation)
+			subjects = append(subjects, *it.Relationship().Subject)
+		}
+		require.NoError(t, it.Err())
+		assert.Equal(t, []string{"owner", "viewer", "viewer"}, relations)
+		// subject sets keep their relation
+		assert.Equal(t, []permify.Subject{
+			{Type: "user", Id: "org.alice"},
+			{Type: "user", Id: "bob"},
+			{Type: "team", Id: "eng", Relation: "member"},
+		}, subjects)
+		assert.False(t, it.Next())
+
+		require.Equal(t, 2, rt.Calls())
+		_, pageSize, token := sentRequest(t, rt.requests[1])
+		assert.Equal(t, 2, pageSize)
+		assert.Equal(t, "page2", token)
+	})
+
+	t.Run("Repeated Token", func(t *testing.T) {
+		client, rt := newTestClient(nil, pages[0])
+
+		count := 0
+		err := client.(permify.DataClient).WalkRelationships(ctx, filter, 2, func(*permify.Relationship) error {
+			count++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, count)
+		assert.Equal(t, 2, rt.Calls())
+	})
+
+	t.Run("Walk Stops On Error", func(t *testing.T) {
+		client, rt := newTestClient(nil, pages...)
+
+		stop := errors.New("stop")
+		count := 0
+		err := client.(permify.DataClient).WalkRelationships(ctx, filter, 2, func(*permify.Relationship) error {
+			count++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 1, rt.Calls())
+	})
+
+	t.Run("Walk Stops On Cancellation", func(t *testing.T) {
+		client, rt := newTestClient(nil, pages...)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		count := 0
+		err := client.(permify.DataClient).WalkRelationships(ctx, filter, 2, func(*permify.Relationship) error {
+			count++
+			cancel()
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 1, rt.Calls())
+	})
+
+	t.Run("Legacy Hyphenated IDs", func(t *testing.T) {
+		legacy := mockStep{status: http.StatusOK, body: `{"tuples": [
+			{"entity": {"type": "document", "id": "org_x-1f"}, "relation": "owner", "subject": {"type": "user", "id": "bob-smith"}}
+		]}`}
+		// the default codec does not read them, the tenant has to be migrated
+		client, _ := newTestClient(nil, legacy)
+		_, err := client.(permify.DataClient).ReadRelationshipsPage(ctx, &permify.ReadRelationshipsRequest{Filter: filter})
+		assert.ErrorIs(t, err, permify.ErrInvalidID)
+
+		// or read with the legacy codec
+		httpClient, _ := newSequenceClient(legacy)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.IDCodec = permify.SeparatorIDCodec{}
+		separating := permify.NewClient(config).(permify.DataClient)
+		response, err := separating.ReadRelationshipsPage(ctx, &permify.ReadRelationshipsRequest{Filter: filter})
+		require.NoError(t, err)
+		require.Len(t, response.Relationships, 1)
+		assert.Equal(t, "org.x-1f", response.Relationships[0].Entity.Id)
+		assert.Equal(t, "bob-smith", response.Relationships[0].Subject.Id)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		client, _ := newTestClient(nil, mockStep{status: http.StatusOK, body: `{"code": 3, "message": "invalid filter"}`})
+
+		it := client.(permify.DataClient).ReadRelationships(ctx, filter, 0)
+		assert.False(t, it.Next())
+		assert.ErrorIs(t, it.Err(), permify.ErrUnableToReadRelationships)
+	})
+}
@@ -11,6 +11,7 @@ type PermissionCheckRequest struct {
 	Entity     *Entity  `json:"entity"`
 	Permission string   `json:"permission"`
 	Subject    *Subject `json:"subject"`
+	Context    *Context `json:"context,omitempty"`
 }
 
 type PermissionCheckResponse struct {
@@ -59,11 +60,28 @@ type ListTenantsResponse struct {
 	ContinuousToken string    `json:"continuous_token,omitempty"`
 }
 
+// SubjectPermissionRequest asks which permissions and relations of Entity
+// Subject holds.
+type SubjectPermissionRequest struct {
+	Metadata        Metadata
+	Entity          *Entity
+	Subject         *Subject
+	OnlyPermissions bool     // Leave out relations
+	Permissions     []string // Names checked one by one when the server lacks the endpoint
+	Context         *Context
+}
+
+type SubjectPermissionResponse struct {
+	Permissions map[string]bool // Permission or relation name to allowed
+	FannedOut   bool            // Answered by one check per name of the request's Permissions
+}
+
 type LookupRelationshipRequest struct {
 	Metadata   Metadata `json:"metadata"`
 	EntityType string   `json:"entity_type"`
 	Permission string   `json:"permission"`
 	Subject    *Subject `json:"subject"`
+	Context    *Context `json:"context,omitempty"`
 }
 
 type LookupRelationshipResponse struct {
@@ -71,14 +89,79 @@ type LookupRelationshipResponse struct {
 	EntityIDs      []string `json:"entity_ids"`
 }
 
+// SubjectReference names a kind of subject, e.g. users or the members of teams.
+type SubjectReference struct {
+	Type     string `json:"type"`
+	Relation string `json:"relation,omitempty"` // e.g. member for team#member, empty for the subject itself
+}
+
+type LookupSubjectRequest struct {
+	Metadata         Metadata         `json:"metadata"`
+	Entity           *Entity          `json:"entity"`
+	Permission       string           `json:"permission"`
+	SubjectReference SubjectReference `json:"subject_reference"`
+	Context          *Context         `json:"context,omitempty"`
+	PageSize         int              `json:"page_size,omitempty"`
+	ContinuousToken  string           `json:"continuous_token,omitempty"`
+}
+
+type LookupSubjectResponse struct {
+	*ErrorResponse  `json:",inline"`
+	SubjectIDs      []string `json:"subject_ids"`
+	ContinuousToken string   `json:"continuous_token,omitempty"`
+}
+
 type FindRelationshipsRequest struct {
 	Metadata   Metadata `json:"metadata"`
 	Entity     *Entity  `json:"entity"`
 	Permission string   `json:"permission"`
+	Context    *Context `json:"context,omitempty"`
 }
 
 type FindRelationshipsResponse = LookupRelationshipResponse
 
+// WriteDataRequest writes relationships and attributes together.
+type WriteDataRequest struct {
+	Metadata      Metadata        `json:"metadata"`
+	Relationships []*Relationship `json:"tuples,omitempty"`
+	Attributes    []*Attribute    `json:"attributes,omitempty"`
+}
+
+// ReadAttributesRequest reads a page of the attributes matching Filter.
+type ReadAttributesRequest struct {
+	Metadata        Metadata        `json:"metadata"`
+	Filter          AttributeFilter `json:"filter"`
+	PageSize        int             `json:"page_size,omitempty"`
+	ContinuousToken string          `json:"continuous_token,omitempty"`
+}
+
+type ReadAttributesResponse struct {
+	*ErrorResponse  `json:",inline"`
+	Attributes      []*Attribute `json:"attributes"`
+	ContinuousToken string       `json:"continuous_token,omitempty"`
+}
+
+// ReadRelationshipsRequest reads a page of the relationships matching Filter.
+type ReadRelationshipsRequest struct {
+	Metadata        Metadata           `json:"metadata"`
+	Filter          RelationshipFilter `json:"filter"`
+	PageSize        int                `json:"page_size,omitempty"`
+	ContinuousToken string             `json:"continuous_token,omitempty"`
+}
+
+type ReadRelationshipsResponse struct {
+	*ErrorResponse  `json:",inline"`
+	Relationships   []*Relationship `json:"tuples"`
+	ContinuousToken string          `json:"continuous_token,omitempty"`
+}
+
+// DeleteDataRequest deletes the relationships and attributes matching its
+// filters, a nil filter deletes nothing of its kind.
+type DeleteDataRequest struct {
+	TupleFilter     *RelationshipFilter `json:"tuple_filter,omitempty"`
+	AttributeFilter *AttributeFilter    `json:"attribute_filter,omitempty"`
+}
+
 type DeleteRelationshipRequest struct {
 	Filter RelationshipFilter `json:"filter"`
 }
@@ -0,0 +1,137 @@
+package permify
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBaseBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff  = 2 * time.Second
+	DefaultRetryJitter      = 0.5
+)
+
+// RetryPolicy controls how failed attempts are retried by the client.
+// A nil policy on the Config means every call is attempted exactly once.
+type RetryPolicy struct {
+	MaxAttempts          int           // Total attempts including the first one
+	BaseBackoff          time.Duration // Backoff before the second attempt, doubled for each further one
+	MaxBackoff           time.Duration // Upper bound for the computed backoff and a server supplied Retry-After
+	Jitter               float64       // Fraction (0..1) of the backoff that is randomised away
+	RetryableStatusCodes []int         // HTTP status codes that are worth another attempt
+	// RetryableOperations lists the operations that may be retried. When it is
+	// empty only idempotent operations are retried; listing a non-idempotent
+	// operation such as OperationWrite is the explicit opt-in for it.
+	RetryableOperations []Operation
+}
+
+// NewDefaultRetryPolicy returns a retry policy suitable for most callers.
+func NewDefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultRetryMaxAttempts,
+		BaseBackoff: DefaultRetryBaseBackoff,
+		MaxBackoff:  DefaultRetryMaxBackoff,
+		Jitter:      DefaultRetryJitter,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// attempts returns the number of attempts allowed for the operation.
+func (p *RetryPolicy) attempts(op Operation) int {
+	if p == nil || p.MaxAttempts <= 1 {
+		return 1
+	}
+	if len(p.RetryableOperations) == 0 {
+		if !op.IsIdempotent() {
+			return 1
+		}
+		return p.MaxAttempts
+	}
+	for _, allowed := range p.RetryableOperations {
+		if allowed == op {
+			return p.MaxAttempts
+		}
+	}
+	return 1
+}
+
+// retryableStatus reports whether the status code warrants another attempt.
+func (p *RetryPolicy) retryableStatus(status int) bool {
+	if p == nil {
+		return false
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait after the given (1 based) attempt failed.
+// A server supplied Retry-After takes precedence over the computed value, it
+// is capped by MaxBackoff too so a server cannot park a call for an hour.
+func (p *RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if p.MaxBackoff > 0 && retryAfter > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return retryAfter
+	}
+	wait := time.Duration(float64(p.BaseBackoff) * math.Pow(2, float64(attempt-1)))
+	if p.MaxBackoff > 0 && (wait > p.MaxBackoff || wait <= 0) {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		wait -= time.Duration(rand.Float64() * jitter * float64(wait))
+	}
+	return wait
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or
+// as an HTTP date. Unparseable or past values yield zero.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// sleepContext waits for the given duration. It returns false without waiting
+// when the context's deadline would pass first, or when the context is done.
+func sleepContext(ctx context.Context, wait time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,218 @@
+package permify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockStep is a single canned reply of a SequenceRoundTripper.
+type mockStep struct {
+	status int
+	body   string
+	header http.Header
+	err    error
+}
+
+// SequenceRoundTripper replies with its steps in order, repeating the last one.
+type SequenceRoundTripper struct {
+	mu       sync.Mutex
+	steps    []mockStep
+	requests []*http.Request
+}
+
+func (m *SequenceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	step := m.steps[len(m.steps)-1]
+	if len(m.requests) < len(m.steps) {
+		step = m.steps[len(m.requests)]
+	}
+	m.requests = append(m.requests, req)
+	if step.err != nil {
+		return nil, step.err
+	}
+	header := step.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: step.status,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewBufferString(step.body)),
+	}, nil
+}
+
+func (m *SequenceRoundTripper) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.requests)
+}
+
+func newSequenceClient(steps ...mockStep) (*http.Client, *SequenceRoundTripper) {
+	rt := &SequenceRoundTripper{steps: steps}
+	return &http.Client{Transport: rt}, rt
+}
+
+// newTestClient returns a client replying with steps, configure may adjust
+// the config before the client is built.
+func newTestClient(configure func(*permify.Config), steps ...mockStep) (permify.RelationshipClient, *SequenceRoundTripper) {
+	httpClient, rt := newSequenceClient(steps...)
+	config := permify.NewDefaultConfig()
+	config.Client = httpClient
+	config.RateLimit = 1000
+	if configure != nil {
+		configure(config)
+	}
+	return permify.NewClient(config), rt
+}
+
+func readYourWrites(config *permify.Config) {
+	config.ReadYourWrites = true
+}
+
+func fastRetryPolicy() *permify.RetryPolicy {
+	policy := permify.NewDefaultRetryPolicy()
+	policy.BaseBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+	write := &permify.AddRelationshipRequest{
+		Relationships: []*permify.Relationship{
+			{
+				Entity:   &permify.Entity{Type: "doc", Id: "doc1"},
+				Relation: "owner",
+				Subject:  &permify.Subject{Type: "user", Id: "user1"},
+			},
+		},
+	}
+
+	t.Run("Retries Server Errors Until Success", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusServiceUnavailable},
+			mockStep{status: http.StatusTooManyRequests},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		client := permify.NewClient(config)
+
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 3, rt.Calls())
+	})
+
+	t.Run("Retries Transport Errors", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{err: errors.New("connection reset")},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		client := permify.NewClient(config)
+
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 2, rt.Calls())
+	})
+
+	t.Run("Stops After Max Attempts", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(mockStep{err: errors.New("connection refused")})
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		client := permify.NewClient(config)
+
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.Error(t, err)
+		assert.Equal(t, permify.DefaultRetryMaxAttempts, rt.Calls())
+	})
+
+	t.Run("Does Not Retry Writes By Default", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusServiceUnavailable},
+			mockStep{status: http.StatusOK, body: `{"snap_token":"snap"}`},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		client := permify.NewClient(config)
+
+		_, err := client.AddRelationship(ctx, write)
+		assert.Error(t, err)
+		assert.Equal(t, 1, rt.Calls())
+	})
+
+	t.Run("Retries Writes When Opted In", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusServiceUnavailable},
+			mockStep{status: http.StatusOK, body: `{"snap_token":"snap"}`},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		config.Retry.RetryableOperations = []permify.Operation{permify.OperationWrite}
+		client := permify.NewClient(config)
+
+		snap, err := client.AddRelationship(ctx, write)
+		assert.NoError(t, err)
+		assert.Equal(t, "snap", snap.SnapToken)
+		assert.Equal(t, 2, rt.Calls())
+	})
+
+	t.Run("Gives Up When Retry-After Exceeds Deadline", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"30"}}},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		config.Retry.MaxBackoff = time.Minute
+		client := permify.NewClient(config)
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		start := time.Now()
+		_, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.Error(t, err)
+		assert.Equal(t, 1, rt.Calls())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Caps Retry-After At MaxBackoff", func(t *testing.T) {
+		httpClient, rt := newSequenceClient(
+			mockStep{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"3600"}}},
+			mockStep{status: http.StatusOK, body: allowed},
+		)
+		config := permify.NewDefaultConfig()
+		config.Client = httpClient
+		config.Retry = fastRetryPolicy()
+		client := permify.NewClient(config)
+
+		start := time.Now()
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 2, rt.Calls())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
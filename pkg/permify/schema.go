@@ -9,7 +9,7 @@ import (
 func (c *client) SaveModelSchema(ctx context.Context, schema *SaveSchemaRequest) (*SaveSchemaResponse, error) {
 	url := c.constructURL(SchemaWriteAPIPath)
 
-	body, err := c.sendRequest(ctx, http.MethodPost, url, schema)
+	body, err := c.sendRequest(ctx, OperationWriteSchema, http.MethodPost, url, schema)
 	if err != nil {
 		return nil, err
 	}
@@ -20,8 +20,12 @@ func (c *client) SaveModelSchema(ctx context.Context, schema *SaveSchemaRequest)
 	}
 
 	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
-		return nil, ErrUnableToWriteSchema
+		return nil, responseError(OperationWriteSchema, url, response.ErrorResponse)
 	}
 
+	c.schemaMu.Lock()
+	c.schema = schema.Schema
+	c.schemaMu.Unlock()
+
 	return &response, nil
 }
@@ -0,0 +1,349 @@
+package permify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SessionHeader is the suggested header to carry an encoded Session between
+// the pods serving a user.
+const SessionHeader = "X-Permify-Session"
+
+// DefaultSessionMaxKeys bounds the per key snap tokens kept by a Session.
+const DefaultSessionMaxKeys = 32
+
+var ErrInvalidSession = errors.New("invalid session")
+
+// SessionOptions configures a Session.
+type SessionOptions struct {
+	// PerKey makes reads carry the newest snap token written for their
+	// entity or subject rather than for the whole tenant, so reads about
+	// objects the session did not touch are not forced to a fresh snapshot.
+	PerKey bool
+	// MaxKeys bounds the number of per key tokens so an encoded session fits
+	// in a cookie. Beyond it the keys are folded into a tenant wide floor.
+	// Defaults to DefaultSessionMaxKeys.
+	MaxKeys int
+	// Compare orders two snap tokens like strings.Compare and returns 0 when
+	// they cannot be ordered. Defaults to CompareSnapTokens.
+	Compare func(a, b string) int
+}
+
+// SessionClient is implemented by clients that can hand out sessions.
+type SessionClient interface {
+	// NewSession returns an empty Session, nil options use the defaults.
+	NewSession(options *SessionOptions) *Session
+	// ResumeSession returns the Session encoded by Session.Encode.
+	ResumeSession(encoded string, options *SessionOptions) (*Session, error)
+}
+
+// Session gives a sequence of calls read-your-writes consistency. It
+// remembers the newest snap token returned by its writes and attaches it to
+// its reads, unless the caller pins a snapshot. A Session can be encoded into
+// a cookie or header and resumed by another client, e.g. on another pod.
+// It is safe for concurrent use.
+type Session struct {
+	client  *client
+	perKey  bool
+	maxKeys int
+	compare func(a, b string) int
+
+	mu    sync.Mutex
+	state sessionState
+}
+
+// sessionState is the encoded form of a Session, tokens are kept per tenant
+// so one session can follow a user across tenants.
+type sessionState struct {
+	Tenants map[string]string            `json:"t,omitempty"` // newest token per tenant
+	Keys    map[string]map[string]string `json:"k,omitempty"` // newest token per tenant and key
+	Floors  map[string]string            `json:"f,omitempty"` // newest token of the keys folded away
+}
+
+// NewSession implements SessionClient.
+func (c *client) NewSession(options *SessionOptions) *Session {
+	s := &Session{client: c, maxKeys: DefaultSessionMaxKeys, compare: CompareSnapTokens}
+	if options != nil {
+		s.perKey = options.PerKey
+		if options.MaxKeys > 0 {
+			s.maxKeys = options.MaxKeys
+		}
+		if options.Compare != nil {
+			s.compare = options.Compare
+		}
+	}
+	return s
+}
+
+// ResumeSession implements SessionClient.
+func (c *client) ResumeSession(encoded string, options *SessionOptions) (*Session, error) {
+	s := c.NewSession(options)
+	if encoded == "" {
+		return s, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSession, err)
+	}
+	if err := json.Unmarshal(raw, &s.state); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSession, err)
+	}
+	return s, nil
+}
+
+// Encode returns the session as a URL and cookie safe string.
+func (s *Session) Encode() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.state.Tenants) == 0 && len(s.state.Keys) == 0 && len(s.state.Floors) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(s.state)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// SnapToken returns the newest snap token seen for the client's tenant.
+func (s *Session) SnapToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Tenants[s.client.config.Tenant]
+}
+
+// Observe records a snap token obtained outside the session, e.g. from a
+// write made by another component on the user's behalf.
+func (s *Session) Observe(token string) {
+	s.observe(token)
+}
+
+// Merge folds the tokens of other into the session, e.g. when two requests
+// of the same user wrote concurrently and both returned a session. Tokens
+// that cannot be ordered resolve to the one of other.
+func (s *Session) Merge(other *Session) {
+	if other == nil || other == s {
+		return
+	}
+	other.mu.Lock()
+	state := other.state.clone()
+	other.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for tenant, token := range state.Tenants {
+		s.set(&s.state.Tenants, tenant, token)
+	}
+	for tenant, token := range state.Floors {
+		s.set(&s.state.Floors, tenant, token)
+	}
+	for tenant, keys := range state.Keys {
+		for key, token := range keys {
+			s.setKey(tenant, key, token)
+		}
+	}
+}
+
+// AddRelationship adds relationships and remembers the snapshot of the write.
+func (s *Session) AddRelationship(ctx context.Context, request *AddRelationshipRequest) (*RelationshipSnap, error) {
+	snap, err := s.client.AddRelationship(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, 2*len(request.Relationships))
+	for _, r := range request.Relationships {
+		keys = append(keys, sessionKey(r.Entity.Type, r.Entity.Id), sessionKey(r.Subject.Type, r.Subject.Id))
+	}
+	s.observe(snap.SnapToken, keys...)
+	return snap, nil
+}
+
+// DeleteRelationship deletes relationships and remembers the snapshot of the deletion.
+func (s *Session) DeleteRelationship(ctx context.Context, filter *DeleteRelationshipRequest) error {
+	snap, err := s.client.deleteRelationship(ctx, filter)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, id := range filter.Filter.Entity.Ids {
+		keys = append(keys, sessionKey(filter.Filter.Entity.Type, id))
+	}
+	for _, id := range filter.Filter.Subject.Ids {
+		keys = append(keys, sessionKey(filter.Filter.Subject.Type, id))
+	}
+	s.observe(snap.SnapToken, keys...)
+	return nil
+}
+
+// CheckPermission is RelationshipClient.CheckPermission at the session's snapshot.
+func (s *Session) CheckPermission(ctx context.Context, who *Subject, what *Entity, permission string) (bool, error) {
+	if who == nil || what == nil {
+		return s.client.CheckPermission(ctx, who, what, permission)
+	}
+	ctx = s.readContext(ctx, sessionKey(what.Type, what.Id), sessionKey(who.Type, who.Id))
+	return s.client.CheckPermission(ctx, who, what, permission)
+}
+
+// Check is RelationshipClient.Check at the session's snapshot.
+func (s *Session) Check(ctx context.Context, request *PermissionCheckRequest, opts ...CheckOption) (*CheckDecision, error) {
+	if request != nil && request.Entity != nil && request.Subject != nil {
+		ctx = s.readContext(ctx, sessionKey(request.Entity.Type, request.Entity.Id), sessionKey(request.Subject.Type, request.Subject.Id))
+	}
+	return s.client.Check(ctx, request, opts...)
+}
+
+// LookupRelationship is RelationshipClient.LookupRelationship at the session's snapshot.
+func (s *Session) LookupRelationship(ctx context.Context, request *LookupRelationshipRequest) (*LookupRelationshipResponse, error) {
+	if request != nil && request.Subject != nil {
+		ctx = s.readContext(ctx, sessionKey(request.Subject.Type, request.Subject.Id))
+	}
+	return s.client.LookupRelationship(ctx, request)
+}
+
+// FindRelationships is RelationshipClient.FindRelationships at the session's snapshot.
+func (s *Session) FindRelationships(ctx context.Context, request *FindRelationshipsRequest) (*FindRelationshipsResponse, error) {
+	if request != nil && request.Entity != nil {
+		ctx = s.readContext(ctx, sessionKey(request.Entity.Type, request.Entity.Id))
+	}
+	return s.client.FindRelationships(ctx, request)
+}
+
+// readContext attaches the session's snap token for keys to ctx, unless the
+// caller pinned one already.
+func (s *Session) readContext(ctx context.Context, keys ...string) context.Context {
+	if consistencyFromContext(ctx).SnapToken != "" {
+		return ctx
+	}
+	if token := s.tokenFor(keys...); token != "" {
+		return WithSnapToken(ctx, token)
+	}
+	return ctx
+}
+
+// tokenFor returns the snap token reads about keys should see.
+func (s *Session) tokenFor(keys ...string) string {
+	tenant := s.client.config.Tenant
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.perKey {
+		return s.state.Tenants[tenant]
+	}
+	token := s.state.Floors[tenant]
+	for _, key := range keys {
+		token = s.newest(token, s.state.Keys[tenant][key])
+	}
+	return token
+}
+
+// observe records the token of a write for the tenant and the touched keys.
+func (s *Session) observe(token string, keys ...string) {
+	if token == "" {
+		return
+	}
+	tenant := s.client.config.Tenant
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(&s.state.Tenants, tenant, token)
+	if !s.perKey {
+		return
+	}
+	for _, key := range keys {
+		s.setKey(tenant, key, token)
+	}
+}
+
+// set merges token into (*m)[tenant], the caller must hold the lock.
+func (s *Session) set(m *map[string]string, tenant, token string) {
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[tenant] = s.newest((*m)[tenant], token)
+}
+
+// setKey merges token into the tokens of key and folds the keys of the
+// tenant into its floor once there are too many, the caller must hold the
+// lock and have merged token into the tenant's token already.
+func (s *Session) setKey(tenant, key, token string) {
+	if s.state.Keys == nil {
+		s.state.Keys = make(map[string]map[string]string)
+	}
+	keys := s.state.Keys[tenant]
+	if keys == nil {
+		keys = make(map[string]string)
+		s.state.Keys[tenant] = keys
+	}
+	keys[key] = s.newest(keys[key], token)
+	if len(keys) <= s.maxKeys {
+		return
+	}
+	// every key token was also merged into the tenant token
+	s.set(&s.state.Floors, tenant, s.state.Tenants[tenant])
+	delete(s.state.Keys, tenant)
+}
+
+// newest merges two snap tokens. When they cannot be ordered the one observed
+// last, b, wins.
+func (s *Session) newest(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	case s.compare(a, b) > 0:
+		return a
+	}
+	return b
+}
+
+func (st sessionState) clone() sessionState {
+	out := sessionState{
+		Tenants: make(map[string]string, len(st.Tenants)),
+		Keys:    make(map[string]map[string]string, len(st.Keys)),
+		Floors:  make(map[string]string, len(st.Floors)),
+	}
+	for tenant, token := range st.Tenants {
+		out.Tenants[tenant] = token
+	}
+	for tenant, token := range st.Floors {
+		out.Floors[tenant] = token
+	}
+	for tenant, keys := range st.Keys {
+		out.Keys[tenant] = make(map[string]string, len(keys))
+		for key, token := range keys {
+			out.Keys[tenant][key] = token
+		}
+	}
+	return out
+}
+
+func sessionKey(typ, id string) string {
+	return typ + ":" + id
+}
+
+// CompareSnapTokens orders snap tokens of Permify's postgres data store,
+// which encode the transaction ID as a base64 little endian uint64, optionally
+// followed by ":" and a snapshot. It returns 0 for tokens it cannot decode,
+// such as those of the in memory data store.
+func CompareSnapTokens(a, b string) int {
+	x, okA := snapTokenTransaction(a)
+	y, okB := snapTokenTransaction(b)
+	switch {
+	case !okA || !okB || x == y:
+		return 0
+	case x < y:
+		return -1
+	}
+	return 1
+}
+
+func snapTokenTransaction(token string) (uint64, bool) {
+	token, _, _ = strings.Cut(token, ":")
+	raw, err := base64.StdEncoding.DecodeString(token)
+	if err != nil || len(raw) != 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(raw), true
+}
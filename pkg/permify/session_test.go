@@ -0,0 +1,139 @@
+package permify_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postgresSnapToken encodes a transaction ID like Permify's postgres data store.
+func postgresSnapToken(xid uint64) string {
+	raw := make([]byte, 8)
+	binary.LittleEndian.PutUint64(raw, xid)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	alice := &permify.Subject{Type: "user", Id: "alice"}
+	bob := &permify.Subject{Type: "user", Id: "bob"}
+	doc := &permify.Entity{Type: "doc", Id: "doc1"}
+	allowed := `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`
+	write := func(token string) mockStep {
+		return mockStep{status: http.StatusOK, body: `{"snap_token": "` + token + `"}`}
+	}
+	add := func(s *permify.Session, entity *permify.Entity, subject *permify.Subject) {
+		_, err := s.AddRelationship(ctx, &permify.AddRelationshipRequest{
+			Relationships: []*permify.Relationship{{Entity: entity, Relation: "viewer", Subject: subject}},
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("Reads See Own Writes", func(t *testing.T) {
+		client, rt := newTestClient(nil, write("snap1"), mockStep{status: http.StatusOK, body: allowed},
+			mockStep{status: http.StatusOK, body: allowed})
+		session := client.(permify.SessionClient).NewSession(nil)
+
+		add(session, doc, alice)
+		assert.Equal(t, "snap1", session.SnapToken())
+		_, err := session.CheckPermission(ctx, alice, doc, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "snap1", sentMetadata(t, rt.requests[1]).Snap)
+
+		// a snapshot pinned by the caller wins
+		_, err = session.CheckPermission(permify.WithSnapToken(ctx, "pinned"), alice, doc, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "pinned", sentMetadata(t, rt.requests[2]).Snap)
+	})
+
+	t.Run("Per Key", func(t *testing.T) {
+		client, rt := newTestClient(nil, write("snap1"),
+			mockStep{status: http.StatusOK, body: `{"entity_ids": []}`},
+			mockStep{status: http.StatusOK, body: `{"entity_ids": []}`})
+		session := client.(permify.SessionClient).NewSession(&permify.SessionOptions{PerKey: true})
+
+		add(session, doc, alice)
+		_, err := session.LookupRelationship(ctx, &permify.LookupRelationshipRequest{EntityType: "doc", Permission: "view", Subject: alice})
+		assert.NoError(t, err)
+		assert.Equal(t, "snap1", sentMetadata(t, rt.requests[1]).Snap)
+
+		// bob was not touched by the session
+		_, err = session.LookupRelationship(ctx, &permify.LookupRelationshipRequest{EntityType: "doc", Permission: "view", Subject: bob})
+		assert.NoError(t, err)
+		assert.Empty(t, sentMetadata(t, rt.requests[2]).Snap)
+	})
+
+	t.Run("Too Many Keys Fold Into A Floor", func(t *testing.T) {
+		client, rt := newTestClient(nil, write("snap1"), write("snap2"), mockStep{status: http.StatusOK, body: allowed})
+		session := client.(permify.SessionClient).NewSession(&permify.SessionOptions{PerKey: true, MaxKeys: 2})
+
+		add(session, doc, alice)
+		add(session, &permify.Entity{Type: "doc", Id: "doc2"}, alice)
+		_, err := session.CheckPermission(ctx, bob, &permify.Entity{Type: "doc", Id: "doc3"}, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "snap2", sentMetadata(t, rt.requests[2]).Snap)
+	})
+
+	t.Run("Encode And Resume", func(t *testing.T) {
+		client, rt := newTestClient(nil, write("snap1"), mockStep{status: http.StatusOK, body: allowed})
+		session := client.(permify.SessionClient).NewSession(nil)
+		assert.Empty(t, session.Encode())
+
+		add(session, doc, alice)
+		encoded := session.Encode()
+		assert.NotContains(t, encoded, "=")
+
+		// another pod resumes the session from the user's cookie
+		resumed, err := client.(permify.SessionClient).ResumeSession(encoded, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "snap1", resumed.SnapToken())
+		_, err = resumed.CheckPermission(ctx, alice, doc, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "snap1", sentMetadata(t, rt.requests[1]).Snap)
+
+		_, err = client.(permify.SessionClient).ResumeSession("not a session!", nil)
+		assert.ErrorIs(t, err, permify.ErrInvalidSession)
+	})
+
+	t.Run("Merge Keeps The Newest Token", func(t *testing.T) {
+		older, newer := postgresSnapToken(41), postgresSnapToken(42)
+		client, _ := newTestClient(nil, write(newer), write(older))
+		first, second := client.(permify.SessionClient).NewSession(nil), client.(permify.SessionClient).NewSession(nil)
+
+		// the two writes finished in the opposite order they were committed in
+		add(first, doc, alice)
+		add(second, doc, bob)
+		first.Merge(second)
+		assert.Equal(t, newer, first.SnapToken())
+
+		second.Merge(first)
+		assert.Equal(t, newer, second.SnapToken())
+
+		// a session also keeps the newest of its own writes
+		second.Observe(older)
+		assert.Equal(t, newer, second.SnapToken())
+	})
+
+	t.Run("Merge Unordered Tokens", func(t *testing.T) {
+		client, _ := newTestClient(nil, write("a"), write("b"))
+		first, second := client.(permify.SessionClient).NewSession(nil), client.(permify.SessionClient).NewSession(nil)
+
+		add(first, doc, alice)
+		add(second, doc, bob)
+		first.Merge(second)
+		assert.Equal(t, "b", first.SnapToken())
+	})
+}
+
+func TestCompareSnapTokens(t *testing.T) {
+	assert.Equal(t, -1, permify.CompareSnapTokens(postgresSnapToken(1), postgresSnapToken(2)))
+	assert.Equal(t, 1, permify.CompareSnapTokens(postgresSnapToken(300), postgresSnapToken(2)))
+	assert.Equal(t, 0, permify.CompareSnapTokens(postgresSnapToken(2), postgresSnapToken(2)+":snapshot"))
+	assert.Equal(t, 0, permify.CompareSnapTokens("memory", postgresSnapToken(2)))
+}
@@ -24,7 +24,7 @@ func (c *client) CreateTenant(ctx context.Context, tenant *CreateTenantRequest)
 		tenant.CreatedAt = time.Now().UTC().Format(time.RFC3339)
 	}
 
-	body, err := c.sendRequest(ctx, http.MethodPost, url, tenant)
+	body, err := c.sendRequest(ctx, OperationCreateTenant, http.MethodPost, url, tenant)
 	if err != nil {
 		return nil, fmt.Errorf("failed to save model schema: %w", err)
 	}
@@ -35,7 +35,7 @@ func (c *client) CreateTenant(ctx context.Context, tenant *CreateTenantRequest)
 	}
 
 	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
-		return nil, ErrUnableToCreateTenant
+		return nil, responseError(OperationCreateTenant, url, response.ErrorResponse)
 	}
 
 	return &response, nil
@@ -45,7 +45,7 @@ func (c *client) DeleteTenant(ctx context.Context, tenantID string) (*DeleteTena
 	url := fmt.Sprintf("%s://%s"+TenantDeleteAPIPath,
 		c.config.Protocol, c.config.Host, c.config.APIVersion, tenantID)
 
-	body, err := c.sendRequest(ctx, http.MethodDelete, url, nil)
+	body, err := c.sendRequest(ctx, OperationDeleteTenant, http.MethodDelete, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to save model schema: %w", err)
 	}
@@ -56,7 +56,7 @@ func (c *client) DeleteTenant(ctx context.Context, tenantID string) (*DeleteTena
 	}
 
 	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
-		return nil, ErrUnableToDeleteTenant
+		return nil, responseError(OperationDeleteTenant, url, response.ErrorResponse)
 	}
 
 	return &response, nil
@@ -67,7 +67,7 @@ func (c *client) ListTenants(ctx context.Context, request *ListTenantsRequest) (
 		c.config.Protocol,
 		c.config.Host,
 		c.config.APIVersion)
-	body, err := c.sendRequest(ctx, http.MethodPost, url, request)
+	body, err := c.sendRequest(ctx, OperationListTenants, http.MethodPost, url, request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to save model schema: %w", err)
 	}
@@ -78,7 +78,7 @@ func (c *client) ListTenants(ctx context.Context, request *ListTenantsRequest) (
 	}
 
 	if response.ErrorResponse != nil && response.ErrorResponse.Code != 0 {
-		return nil, ErrUnableToListTenant
+		return nil, responseError(OperationListTenants, url, response.ErrorResponse)
 	}
 
 	return &response, nil
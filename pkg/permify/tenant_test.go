@@ -19,6 +19,7 @@ func TestCreateTenant(t *testing.T) {
 		id       string
 		status   int
 		expected *permify.CreateTenantResponse
+		wantErr  bool
 	}{
 		{
 			name:   "successful request",
@@ -37,6 +38,7 @@ func TestCreateTenant(t *testing.T) {
 			expected: &permify.CreateTenantResponse{
 				Tenant: &permify.Tenant{},
 			},
+			wantErr: true,
 		},
 	}
 
@@ -53,6 +55,14 @@ func TestCreateTenant(t *testing.T) {
 				},
 			)
 
+			if tt.wantErr {
+				var apiErr *permify.APIError
+				assert.ErrorIs(t, err, permify.ErrUnableToCreateTenant)
+				assert.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.status, apiErr.StatusCode)
+				assert.Nil(t, resp)
+				return
+			}
 			assert.NoError(t, err)
 			assert.NotNil(t, resp)
 			assert.NotNil(t, resp.Tenant)
@@ -0,0 +1,366 @@
+package permify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header used to propagate the trace context, see https://www.w3.org/TR/trace-context/
+const TraceParentHeader = "traceparent"
+
+// Span attribute keys set by the client.
+const (
+	AttributeOperation  = "permify.operation"
+	AttributeTenant     = "permify.tenant"
+	AttributeEntityType = "permify.entity_type"
+	AttributePermission = "permify.permission"
+	AttributeDepth      = "permify.depth"
+	AttributeSnapToken  = "permify.snap_token"
+	AttributeCheckCount = "permify.check_count"
+	AttributeItems      = "permify.items"
+	AttributeAttempts   = "permify.attempts"
+	AttributeStatusCode = "http.status_code"
+)
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether both the trace and span ID are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent renders the span context as a W3C traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceParent parses a W3C traceparent header value, e.g. to continue
+// the trace of an incoming request with ContextWithSpanContext.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context whose spans continue the given trace.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Tracer starts spans. Implementations can bridge to OpenTelemetry or any
+// other tracing system.
+type Tracer interface {
+	// Start begins a span as a child of the span context carried by ctx and
+	// returns a context carrying the new span's context.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single timed operation of a trace.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+	SpanContext() SpanContext
+}
+
+// NoopTracer is the default Tracer, its spans record nothing. The trace
+// context of the caller, if any, is still propagated to Permify.
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	sc, _ := SpanContextFromContext(ctx)
+	return ctx, noopSpan{sc: sc}
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+func (s noopSpan) SpanContext() SpanContext                 { return s.sc }
+
+// SpanRecord is a finished span as stored by the RecordingTracer.
+type SpanRecord struct {
+	Name       string                 `json:"name"`
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Duration   time.Duration          `json:"duration_ns"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// RecordingTracer keeps finished spans in memory so they can be dumped to
+// JSON for offline analysis.
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []SpanRecord
+}
+
+// NewRecordingTracer returns an empty RecordingTracer.
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+func (t *RecordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordingSpan{tracer: t, record: SpanRecord{Name: name, Start: time.Now()}}
+	span.sc.Sampled = true
+	if parent, ok := SpanContextFromContext(ctx); ok && parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.record.ParentID = hex.EncodeToString(parent.SpanID[:])
+	} else {
+		_, _ = rand.Read(span.sc.TraceID[:])
+	}
+	_, _ = rand.Read(span.sc.SpanID[:])
+	span.record.TraceID = hex.EncodeToString(span.sc.TraceID[:])
+	span.record.SpanID = hex.EncodeToString(span.sc.SpanID[:])
+	return ContextWithSpanContext(ctx, span.sc), span
+}
+
+// Spans returns a copy of the finished spans.
+func (t *RecordingTracer) Spans() []SpanRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SpanRecord(nil), t.spans...)
+}
+
+// WriteJSON writes the finished spans as a JSON array.
+func (t *RecordingTracer) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t.Spans())
+}
+
+// DumpJSON writes the finished spans to a JSON file at path.
+func (t *RecordingTracer) DumpJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create span file: %w", err)
+	}
+	if err := t.WriteJSON(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spans: %w", err)
+	}
+	return f.Close()
+}
+
+type recordingSpan struct {
+	tracer *RecordingTracer
+	sc     SpanContext
+
+	mu     sync.Mutex
+	record SpanRecord
+	ended  bool
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.record.Attributes == nil {
+		s.record.Attributes = make(map[string]interface{})
+	}
+	s.record.Attributes[key] = value
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.Error = err.Error()
+}
+
+func (s *recordingSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.record.End = time.Now()
+	s.record.Duration = s.record.End.Sub(s.record.Start)
+	record := s.record
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, record)
+}
+
+func (s *recordingSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+// spanAnnotator is implemented by payloads that describe themselves on a span.
+type spanAnnotator interface {
+	annotate(span Span)
+}
+
+func annotateMetadata(span Span, metadata Metadata) {
+	if metadata.Depth != 0 {
+		span.SetAttribute(AttributeDepth, metadata.Depth)
+	}
+	if metadata.Snap != "" {
+		span.SetAttribute(AttributeSnapToken, metadata.Snap)
+	}
+}
+
+func (r *PermissionCheckRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	if r.Entity != nil {
+		span.SetAttribute(AttributeEntityType, r.Entity.Type)
+	}
+	span.SetAttribute(AttributePermission, r.Permission)
+}
+
+func (r *bulkCheckRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	span.SetAttribute(AttributeItems, len(r.Items))
+}
+
+func (r *subjectPermissionRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata.Metadata)
+	if r.Entity != nil {
+		span.SetAttribute(AttributeEntityType, r.Entity.Type)
+	}
+}
+
+func (r *LookupRelationshipRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	span.SetAttribute(AttributeEntityType, r.EntityType)
+	span.SetAttribute(AttributePermission, r.Permission)
+}
+
+func (r *LookupSubjectRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	if r.Entity != nil {
+		span.SetAttribute(AttributeEntityType, r.Entity.Type)
+	}
+	span.SetAttribute(AttributePermission, r.Permission)
+}
+
+func (r *FindRelationshipsRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	if r.Entity != nil {
+		span.SetAttribute(AttributeEntityType, r.Entity.Type)
+	}
+	span.SetAttribute(AttributePermission, r.Permission)
+}
+
+func (r *AddRelationshipRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	if len(r.Relationships) > 0 && r.Relationships[0] != nil && r.Relationships[0].Entity != nil {
+		span.SetAttribute(AttributeEntityType, r.Relationships[0].Entity.Type)
+	}
+}
+
+func (r *DeleteRelationshipRequest) annotate(span Span) {
+	span.SetAttribute(AttributeEntityType, r.Filter.Entity.Type)
+}
+
+func (r *WriteDataRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	span.SetAttribute(AttributeItems, len(r.Relationships)+len(r.Attributes))
+}
+
+func (r *ReadAttributesRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	span.SetAttribute(AttributeEntityType, r.Filter.Entity.Type)
+}
+
+func (r *ReadRelationshipsRequest) annotate(span Span) {
+	annotateMetadata(span, r.Metadata)
+	span.SetAttribute(AttributeEntityType, r.Filter.Entity.Type)
+}
+
+// tracedResponse picks the fields worth recording from any response body.
+type tracedResponse struct {
+	SnapToken string `json:"snap_token"`
+	Metadata  struct {
+		CheckCount int `json:"check_count"`
+	} `json:"metadata"`
+}
+
+// startSpan begins the span of a call and propagates its context to Permify.
+func (c *client) startSpan(ctx context.Context, req *Request) (context.Context, Span) {
+	ctx, span := c.config.Tracer.Start(ctx, "permify."+string(req.Operation))
+	span.SetAttribute(AttributeOperation, string(req.Operation))
+	span.SetAttribute(AttributeTenant, req.Tenant)
+	if annotator, ok := req.Payload.(spanAnnotator); ok {
+		annotator.annotate(span)
+	}
+	if sc := span.SpanContext(); sc.IsValid() {
+		req.Header.Set(TraceParentHeader, sc.TraceParent())
+	}
+	return ctx, span
+}
+
+// endSpan records the outcome of a call and ends its span.
+func endSpan(span Span, resp *Response, err error) {
+	if resp != nil {
+		span.SetAttribute(AttributeStatusCode, resp.StatusCode)
+		span.SetAttribute(AttributeAttempts, resp.Attempts)
+		var traced tracedResponse
+		if json.Unmarshal(resp.Body, &traced) == nil {
+			if traced.SnapToken != "" {
+				span.SetAttribute(AttributeSnapToken, traced.SnapToken)
+			}
+			if traced.Metadata.CheckCount != 0 {
+				span.SetAttribute(AttributeCheckCount, traced.Metadata.CheckCount)
+			}
+		}
+	}
+	span.RecordError(err)
+	span.End()
+}
@@ -0,0 +1,131 @@
+package permify_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/slimdevl/repro/pkg/permify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	subject := &permify.Subject{Type: "user", Id: "user123"}
+	entity := &permify.Entity{Type: "workspace", Id: "ws123"}
+
+	withTracer := func(tracer permify.Tracer) func(*permify.Config) {
+		return func(config *permify.Config) {
+			config.Retry = fastRetryPolicy()
+			config.Retry.RetryableStatusCodes = []int{http.StatusServiceUnavailable}
+			config.Tracer = tracer
+		}
+	}
+
+	t.Run("Span Per Call", func(t *testing.T) {
+		tracer := permify.NewRecordingTracer()
+		client, rt := newTestClient(withTracer(tracer),
+			mockStep{status: http.StatusServiceUnavailable},
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {"check_count": 4}}`},
+		)
+
+		ok, err := client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		spans := tracer.Spans()
+		if assert.Len(t, spans, 1) {
+			span := spans[0]
+			assert.Equal(t, "permify.check", span.Name)
+			assert.Empty(t, span.ParentID)
+			assert.Empty(t, span.Error)
+			assert.Equal(t, "check", span.Attributes[permify.AttributeOperation])
+			assert.Equal(t, "t1", span.Attributes[permify.AttributeTenant])
+			assert.Equal(t, "workspace", span.Attributes[permify.AttributeEntityType])
+			assert.Equal(t, "view", span.Attributes[permify.AttributePermission])
+			assert.Equal(t, 100, span.Attributes[permify.AttributeDepth])
+			assert.Equal(t, 4, span.Attributes[permify.AttributeCheckCount])
+			assert.Equal(t, 2, span.Attributes[permify.AttributeAttempts])
+			assert.Equal(t, http.StatusOK, span.Attributes[permify.AttributeStatusCode])
+
+			// every attempt carries the span's trace context
+			assert.Equal(t, 2, rt.Calls())
+			want := "00-" + span.TraceID + "-" + span.SpanID + "-01"
+			for _, req := range rt.requests {
+				assert.Equal(t, want, req.Header.Get(permify.TraceParentHeader))
+			}
+		}
+	})
+
+	t.Run("Continues Incoming Trace", func(t *testing.T) {
+		parent, err := permify.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		assert.NoError(t, err)
+
+		tracer := permify.NewRecordingTracer()
+		client, _ := newTestClient(withTracer(tracer), mockStep{status: http.StatusBadRequest, body: `{"code":3,"message":"bad"}`})
+
+		_, err = client.CheckPermission(permify.ContextWithSpanContext(ctx, parent), subject, entity, "view")
+		assert.Error(t, err)
+
+		spans := tracer.Spans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID)
+			assert.Equal(t, "00f067aa0ba902b7", spans[0].ParentID)
+			assert.NotEmpty(t, spans[0].Error)
+		}
+	})
+
+	t.Run("Noop Propagates Caller Context", func(t *testing.T) {
+		parent, _ := permify.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		client, rt := newTestClient(withTracer(nil), mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`},
+			mockStep{status: http.StatusOK, body: `{"can": "CHECK_RESULT_ALLOWED", "metadata": {}}`})
+
+		_, err := client.CheckPermission(permify.ContextWithSpanContext(ctx, parent), subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, parent.TraceParent(), rt.requests[0].Header.Get(permify.TraceParentHeader))
+
+		_, err = client.CheckPermission(ctx, subject, entity, "view")
+		assert.NoError(t, err)
+		assert.Empty(t, rt.requests[1].Header.Get(permify.TraceParentHeader))
+	})
+
+	t.Run("Dump JSON", func(t *testing.T) {
+		tracer := permify.NewRecordingTracer()
+		client, _ := newTestClient(withTracer(tracer), mockStep{status: http.StatusOK, body: `{"snap_token": "snap1"}`})
+
+		_, err := client.AddRelationship(ctx, &permify.AddRelationshipRequest{
+			Relationships: []*permify.Relationship{{Entity: entity, Relation: "member", Subject: subject}},
+		})
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		assert.NoError(t, tracer.WriteJSON(&buf))
+		var spans []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &spans))
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "permify.write", spans[0]["name"])
+			attributes := spans[0]["attributes"].(map[string]interface{})
+			assert.Equal(t, "snap1", attributes[permify.AttributeSnapToken])
+		}
+	})
+}
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := permify.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.NoError(t, err)
+	assert.False(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", sc.TraceParent())
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzf067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		_, err := permify.ParseTraceParent(value)
+		assert.ErrorIs(t, err, permify.ErrInvalidTraceParent, value)
+	}
+}